@@ -0,0 +1,265 @@
+package config
+
+import (
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/HwHgoo/Gredis/utils"
+)
+
+// ServerProperties holds the running configuration of the server.
+// A snapshot is never modified, CONFIG SET replaces it with a new one.
+type ServerProperties struct {
+	Bind           []string
+	Port           int
+	UnixSocket     string
+	UnixSocketPerm int
+	Databases      int
+	MaxClients     int
+	Timeout        int
+	TCPKeepAlive   int
+
+	ClientOutputBufferLimits [ClientClassCount]ClientBufferLimit
+	Dir                      string
+
+	TLSPort        int
+	TLSCertFile    string
+	TLSKeyFile     string
+	TLSCACertFile  string
+	TLSCACertDir   string
+	TLSAuthClients string
+
+	ShutdownTimeout int
+
+	ShardCount int
+
+	// maximum size of a bulk string in a request
+	ProtoMaxBulkLen int64
+
+	// pairs of command name and new name given by rename-command,
+	// in order. An empty new name disables the command.
+	RenamedCommands [][2]string
+}
+
+var current atomic.Pointer[ServerProperties]
+
+// Properties returns the current configuration snapshot
+func Properties() *ServerProperties {
+	return current.Load()
+}
+
+const (
+	flag_none = 0
+	// the option can't be changed by CONFIG SET
+	flag_immutable = 1 << iota
+	// the option takes several space separated arguments in the config file
+	flag_multi_arg
+)
+
+type entry struct {
+	name  string
+	alias string
+	flags int
+	// returns a pointer to the field of p holding the option
+	ptr   func(p *ServerProperties) any
+	value value
+	// apply is called after the value has been changed, so that the change
+	// can take effect. Returning an error rolls the change back.
+	apply func() error
+}
+
+var entries = []*entry{
+	{name: "bind", flags: flag_immutable | flag_multi_arg, ptr: func(p *ServerProperties) any { return &p.Bind }, value: &stringListValue{}},
+	{name: "port", flags: flag_immutable, ptr: func(p *ServerProperties) any { return &p.Port }, value: &intValue{def: 3301, min: 0, max: 65535}},
+	{name: "unixsocket", flags: flag_immutable, ptr: func(p *ServerProperties) any { return &p.UnixSocket }, value: &stringValue{}},
+	{name: "unixsocketperm", flags: flag_immutable, ptr: func(p *ServerProperties) any { return &p.UnixSocketPerm }, value: &octalValue{}},
+	{name: "tls-port", flags: flag_immutable, ptr: func(p *ServerProperties) any { return &p.TLSPort }, value: &intValue{def: 0, min: 0, max: 65535}},
+	{name: "tls-cert-file", ptr: func(p *ServerProperties) any { return &p.TLSCertFile }, value: &stringValue{}},
+	{name: "tls-key-file", ptr: func(p *ServerProperties) any { return &p.TLSKeyFile }, value: &stringValue{}},
+	{name: "tls-ca-cert-file", ptr: func(p *ServerProperties) any { return &p.TLSCACertFile }, value: &stringValue{}},
+	{name: "tls-ca-cert-dir", ptr: func(p *ServerProperties) any { return &p.TLSCACertDir }, value: &stringValue{}},
+	{name: "tls-auth-clients", ptr: func(p *ServerProperties) any { return &p.TLSAuthClients }, value: &enumValue{def: "yes", options: []string{"yes", "no", "optional"}}},
+	{name: "databases", flags: flag_immutable, ptr: func(p *ServerProperties) any { return &p.Databases }, value: &intValue{def: 16, min: 1, max: 1 << 16}},
+	{name: "maxclients", ptr: func(p *ServerProperties) any { return &p.MaxClients }, value: &intValue{def: 10000, min: 1, max: math.MaxInt32}},
+	{name: "timeout", ptr: func(p *ServerProperties) any { return &p.Timeout }, value: &intValue{def: 0, min: 0, max: math.MaxInt32}},
+	{name: "tcp-keepalive", ptr: func(p *ServerProperties) any { return &p.TCPKeepAlive }, value: &intValue{def: 300, min: 0, max: math.MaxInt32}},
+	{name: "client-output-buffer-limit", flags: flag_multi_arg, ptr: func(p *ServerProperties) any { return &p.ClientOutputBufferLimits }, value: &clientBufferLimitValue{def: [ClientClassCount]ClientBufferLimit{
+		ClientClassNormal:  {Hard: 0, Soft: 0, SoftSeconds: 0},
+		ClientClassReplica: {Hard: 256 << 20, Soft: 64 << 20, SoftSeconds: 60},
+		ClientClassPubSub:  {Hard: 32 << 20, Soft: 8 << 20, SoftSeconds: 60},
+	}}},
+	{name: "dir", ptr: func(p *ServerProperties) any { return &p.Dir }, value: &dirValue{}, apply: func() error { return os.Chdir(Properties().Dir) }},
+	{name: "shutdown-timeout", ptr: func(p *ServerProperties) any { return &p.ShutdownTimeout }, value: &intValue{def: 10, min: 0, max: math.MaxInt32}},
+	{name: "proto-max-bulk-len", ptr: func(p *ServerProperties) any { return &p.ProtoMaxBulkLen }, value: &memoryValue{def: 512 << 20, min: 1 << 20, max: math.MaxInt32}},
+	{name: "db-shard-count", flags: flag_immutable, ptr: func(p *ServerProperties) any { return &p.ShardCount }, value: &intValue{def: 32, min: 1, max: 1 << 16}},
+}
+
+var (
+	lock sync.Mutex
+	// absolute path of the config file the server was started with
+	configFile string
+)
+
+var (
+	ErrImmutable    = errors.New("can't set immutable config")
+	ErrNoConfigFile = errors.New("The server is running without a config file")
+)
+
+func init() {
+	current.Store(defaults())
+}
+
+func defaults() *ServerProperties {
+	p := &ServerProperties{}
+	for _, e := range entries {
+		e.value.reset(e.ptr(p))
+	}
+	return p
+}
+
+// clone returns a copy of the current snapshot to be modified
+func clone() *ServerProperties {
+	p := *current.Load()
+	return &p
+}
+
+func lookup(name string) *entry {
+	name = strings.ToLower(name)
+	for _, e := range entries {
+		if e.name == name || (e.alias != "" && e.alias == name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// OnApply installs fn to be called after the option has been changed by
+// CONFIG SET, so that packages owning the option can make the change take
+// effect. Returning an error rolls the change back.
+func OnApply(name string, fn func() error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	e := lookup(name)
+	if e == nil {
+		panic("unknown config option: " + name)
+	}
+	e.apply = fn
+}
+
+// Get returns name-value pairs of every option matching any of the
+// glob-style patterns, sorted by name.
+func Get(patterns ...string) [][2]string {
+	p := current.Load()
+	result := make([][2]string, 0)
+	for _, e := range entries {
+		for _, pattern := range patterns {
+			if utils.GlobMatch(pattern, e.name, true) {
+				result = append(result, [2]string{e.name, e.value.get(e.ptr(p))})
+				break
+			}
+			if e.alias != "" && utils.GlobMatch(pattern, e.alias, true) {
+				result = append(result, [2]string{e.alias, e.value.get(e.ptr(p))})
+				break
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i][0] < result[j][0] })
+	return result
+}
+
+// SetError reports which option made a CONFIG SET fail
+type SetError struct {
+	Name string
+	Err  error
+}
+
+func (e *SetError) Error() string {
+	return "CONFIG SET failed (possibly related to argument '" + e.Name + "') - " + e.Err.Error()
+}
+
+// Set changes the given options at runtime. Either all of them are changed
+// or, if any of them fails, none of them.
+func Set(pairs [][2]string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	targets := make([]*entry, len(pairs))
+	for i, pair := range pairs {
+		e := lookup(pair[0])
+		if e == nil {
+			return errors.New("Unknown option or number of arguments for CONFIG SET - '" + pair[0] + "'")
+		}
+		if e.flags&flag_immutable != 0 {
+			return &SetError{pair[0], ErrImmutable}
+		}
+		for _, t := range targets[:i] {
+			if t == e {
+				return &SetError{pair[0], errors.New("duplicate parameter")}
+			}
+		}
+		targets[i] = e
+	}
+
+	p := clone()
+	for i, pair := range pairs {
+		if err := targets[i].value.set(targets[i].ptr(p), pair[1]); err != nil {
+			return &SetError{pair[0], err}
+		}
+	}
+
+	old := current.Swap(p)
+	if e, err := applyAll(targets); err != nil {
+		current.Store(old)
+		_, _ = applyAll(targets)
+		return &SetError{e.name, err}
+	}
+	return nil
+}
+
+// Restore makes p, e.g. a snapshot returned by Properties earlier, the
+// current configuration again. A nil p restores the defaults. Only the
+// options which change are applied, so an unset dir keeps the working directory.
+func Restore(p *ServerProperties) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if p == nil {
+		p = defaults()
+	}
+	old := current.Load()
+	changed := make([]*entry, 0)
+	for _, e := range entries {
+		if e.value.get(e.ptr(old)) != e.value.get(e.ptr(p)) {
+			changed = append(changed, e)
+		}
+	}
+
+	current.Store(p)
+	if e, err := applyAll(changed); err != nil {
+		current.Store(old)
+		_, _ = applyAll(changed)
+		return errors.New("failed to apply '" + e.name + "': " + err.Error())
+	}
+	return nil
+}
+
+// applyAll calls the apply hook of each entry once, returning the first failure
+func applyAll(targets []*entry) (*entry, error) {
+	called := make(map[*entry]bool)
+	for _, e := range targets {
+		if e.apply == nil || called[e] {
+			continue
+		}
+		called[e] = true
+		if err := e.apply(); err != nil {
+			return e, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func resetConfig() {
+	current.Store(defaults())
+	configFile = ""
+}
+
+func writeConfig(dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	So(os.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	Convey("TestLoad", t, func() {
+		resetConfig()
+		dir := t.TempDir()
+
+		Convey("defaults without config file", func() {
+			So(Load("", ""), ShouldBeNil)
+			So(Properties().Port, ShouldEqual, 3301)
+			So(Properties().Databases, ShouldEqual, 16)
+			So(Properties().Bind, ShouldBeEmpty)
+		})
+
+		Convey("options, comments and quoted values", func() {
+			path := writeConfig(dir, "redis.conf", "# comment\n\nport 7000\nbind 127.0.0.1 \"::1\"\n  databases 4\n")
+			So(Load(path, ""), ShouldBeNil)
+			So(Properties().Port, ShouldEqual, 7000)
+			So(Properties().Bind, ShouldResemble, []string{"127.0.0.1", "::1"})
+			So(Properties().Databases, ShouldEqual, 4)
+		})
+
+		Convey("include directive", func() {
+			inc := writeConfig(dir, "inc.conf", "databases 2\n")
+			path := writeConfig(dir, "redis.conf", "port 7001\ninclude "+inc+"\n")
+			So(Load(path, ""), ShouldBeNil)
+			So(Properties().Port, ShouldEqual, 7001)
+			So(Properties().Databases, ShouldEqual, 2)
+		})
+
+		Convey("command line overrides config file", func() {
+			path := writeConfig(dir, "redis.conf", "port 7002\ndatabases 3\n")
+			So(LoadFromArgs([]string{path, "--port", "7003", "--bind", "127.0.0.1", "::1"}), ShouldBeNil)
+			So(Properties().Port, ShouldEqual, 7003)
+			So(Properties().Databases, ShouldEqual, 3)
+			So(Properties().Bind, ShouldResemble, []string{"127.0.0.1", "::1"})
+		})
+
+		Convey("rename-command may be repeated", func() {
+			path := writeConfig(dir, "redis.conf", "rename-command config cfg\nrename-command flushall \"\"\n")
+			So(LoadFromArgs([]string{path, "--rename-command", "keys", ""}), ShouldBeNil)
+			So(Properties().RenamedCommands, ShouldResemble, [][2]string{{"config", "cfg"}, {"flushall", ""}, {"keys", ""}})
+			So(Get("rename-command"), ShouldBeEmpty)
+
+			So(Load("", ""), ShouldBeNil)
+			So(Properties().RenamedCommands, ShouldBeEmpty)
+			So(Load("", "rename-command config"), ShouldNotBeNil)
+		})
+
+		Convey("bad lines report their position", func() {
+			path := writeConfig(dir, "redis.conf", "port 7004\nport abc\n")
+			err := Load(path, "")
+			So(err, ShouldNotBeNil)
+			le, ok := err.(*LoadError)
+			So(ok, ShouldBeTrue)
+			So(le.Line, ShouldEqual, 2)
+
+			So(Load("", "no-such-option 1"), ShouldNotBeNil)
+			So(Load("", "port 1 2"), ShouldNotBeNil)
+			So(Load("", "port \"1"), ShouldNotBeNil)
+			So(Load("", "port 70000"), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGetSet(t *testing.T) {
+	Convey("TestGetSet", t, func() {
+		resetConfig()
+
+		Convey("get with glob patterns", func() {
+			pairs := Get("port")
+			So(pairs, ShouldResemble, [][2]string{{"port", "3301"}})
+
+			pairs = Get("d*s*")
+			So(pairs, ShouldResemble, [][2]string{{"databases", "16"}, {"db-shard-count", "32"}})
+
+			pairs = Get("PORT", "bin?")
+			So(pairs, ShouldResemble, [][2]string{{"bind", ""}, {"port", "3301"}})
+
+			So(Get("nothing*"), ShouldBeEmpty)
+		})
+
+		Convey("set mutable option", func() {
+			So(Set([][2]string{{"shutdown-timeout", "128"}}), ShouldBeNil)
+			So(Properties().ShutdownTimeout, ShouldEqual, 128)
+		})
+
+		Convey("set immutable or unknown option", func() {
+			err := Set([][2]string{{"port", "7000"}})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "immutable")
+			So(Properties().Port, ShouldEqual, 3301)
+
+			err = Set([][2]string{{"foo", "bar"}})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "Unknown option")
+		})
+
+		Convey("set client output buffer limits", func() {
+			So(Get("client-output-buffer-limit")[0][1], ShouldEqual, "normal 0 0 0 slave 268435456 67108864 60 pubsub 33554432 8388608 60")
+
+			So(Set([][2]string{{"client-output-buffer-limit", "pubsub 1mb 1k 10 normal 1gb 0 0"}}), ShouldBeNil)
+			limits := Properties().ClientOutputBufferLimits
+			So(limits[ClientClassPubSub], ShouldResemble, ClientBufferLimit{Hard: 1 << 20, Soft: 1000, SoftSeconds: 10})
+			So(limits[ClientClassNormal], ShouldResemble, ClientBufferLimit{Hard: 1 << 30})
+			So(limits[ClientClassReplica].Hard, ShouldEqual, 256<<20)
+
+			So(Set([][2]string{{"client-output-buffer-limit", "pubsub 1mb 1k"}}), ShouldNotBeNil)
+			So(Set([][2]string{{"client-output-buffer-limit", "master 1mb 1k 10"}}), ShouldNotBeNil)
+			So(Set([][2]string{{"client-output-buffer-limit", "normal 1xb 0 0"}}), ShouldNotBeNil)
+		})
+
+		Convey("set memory option", func() {
+			So(Get("proto-max-bulk-len"), ShouldResemble, [][2]string{{"proto-max-bulk-len", "536870912"}})
+			So(Set([][2]string{{"proto-max-bulk-len", "1gb"}}), ShouldBeNil)
+			So(Properties().ProtoMaxBulkLen, ShouldEqual, 1<<30)
+			So(Set([][2]string{{"proto-max-bulk-len", "1k"}}), ShouldNotBeNil)
+			So(Set([][2]string{{"proto-max-bulk-len", "-1"}}), ShouldNotBeNil)
+		})
+
+		Convey("restore a snapshot or the defaults", func() {
+			saved := Properties()
+			So(Set([][2]string{{"shutdown-timeout", "20"}}), ShouldBeNil)
+			So(Restore(saved), ShouldBeNil)
+			So(Properties().ShutdownTimeout, ShouldEqual, 10)
+
+			So(Load("", "databases 4"), ShouldBeNil)
+			So(Restore(nil), ShouldBeNil)
+			So(Properties().Databases, ShouldEqual, 16)
+		})
+
+		Convey("failed set rolls back all options", func() {
+			err := Set([][2]string{{"shutdown-timeout", "20"}, {"dir", "/no/such/dir"}})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "'dir'")
+			So(Properties().ShutdownTimeout, ShouldEqual, 10)
+		})
+	})
+}
+
+func TestRewrite(t *testing.T) {
+	Convey("TestRewrite", t, func() {
+		resetConfig()
+		dir := t.TempDir()
+
+		Convey("without config file", func() {
+			So(Rewrite(), ShouldEqual, ErrNoConfigFile)
+		})
+
+		Convey("keeps comments and updates options in place", func() {
+			inc := writeConfig(dir, "inc.conf", "databases 2\n")
+			path := writeConfig(dir, "redis.conf", "# head comment\nshutdown-timeout 10\n# trailing comment\ninclude "+inc+"\nshutdown-timeout 11\n")
+			So(Load(path, "bind 127.0.0.1"), ShouldBeNil)
+			So(Set([][2]string{{"shutdown-timeout", "32"}}), ShouldBeNil)
+
+			So(Rewrite(), ShouldBeNil)
+			content, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+			So(lines, ShouldResemble, []string{
+				"# head comment",
+				"shutdown-timeout 32",
+				"# trailing comment",
+				"include " + inc,
+				rewrite_signature,
+				"bind 127.0.0.1",
+				"databases 2",
+			})
+
+			// rewriting again is stable
+			So(Rewrite(), ShouldBeNil)
+			again, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(again), ShouldEqual, string(content))
+		})
+	})
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/HwHgoo/Gredis/utils"
+)
+
+const max_include_depth = 16
+
+// LoadError describes the offending line of a configuration
+type LoadError struct {
+	Source string
+	Line   int
+	Text   string
+	Err    error
+}
+
+func (e *LoadError) Error() string {
+	return "reading the configuration file " + e.Source + ", at line " + strconv.Itoa(e.Line) +
+		"\n>>> '" + e.Text + "'\n" + e.Err.Error()
+}
+
+// LoadFromArgs loads the configuration from command line arguments of the form
+//
+//	gredis [/path/to/redis.conf] [--option value ...]
+//
+// Options given on the command line override the ones in the config file.
+func LoadFromArgs(args []string) error {
+	path := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		path, args = args[0], args[1:]
+	}
+
+	var options strings.Builder
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--") {
+			if i != 0 {
+				options.WriteByte('\n')
+			}
+			options.WriteString(arg[2:])
+		} else {
+			options.WriteByte(' ')
+			options.WriteString(utils.QuoteArg(arg))
+		}
+	}
+
+	return Load(path, options.String())
+}
+
+// Load reads the config file at path, if any, then the options in
+// the given string which has the same format as the config file.
+func Load(path string, options string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	l := &loader{p: clone()}
+	l.p.RenamedCommands = nil
+	abs := ""
+	if path != "" {
+		var err error
+		if abs, err = filepath.Abs(path); err != nil {
+			return err
+		}
+		if err := l.loadFile(abs, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := l.loadString(options, "command line", 0); err != nil {
+		return err
+	}
+
+	old := current.Swap(l.p)
+	if e, err := applyAll(l.touched); err != nil {
+		current.Store(old)
+		_, _ = applyAll(l.touched)
+		return errors.New("failed to apply '" + e.name + "': " + err.Error())
+	}
+	if abs != "" {
+		configFile = abs
+	}
+	return nil
+}
+
+// loader reads options into a snapshot which is made current once
+// the whole configuration has been read successfully
+type loader struct {
+	p       *ServerProperties
+	touched []*entry
+}
+
+func (l *loader) loadFile(path string, depth int) error {
+	if depth > max_include_depth {
+		return errors.New("too many nested includes in " + path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return l.loadString(string(content), path, depth)
+}
+
+func (l *loader) loadString(content string, source string, depth int) error {
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if err := l.loadLine(line, depth); err != nil {
+			return &LoadError{Source: source, Line: i + 1, Text: line, Err: err}
+		}
+	}
+	return nil
+}
+
+func (l *loader) loadLine(line string, depth int) error {
+	args, err := utils.SplitArgs(line)
+	if err != nil {
+		return errors.New("Unbalanced quotes in configuration line")
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	name := strings.ToLower(args[0])
+	if name == "include" {
+		if len(args) != 2 {
+			return errors.New("wrong number of arguments")
+		}
+		return l.loadFile(args[1], depth+1)
+	}
+	if name == "rename-command" {
+		if len(args) != 3 {
+			return errors.New("wrong number of arguments")
+		}
+		l.p.RenamedCommands = append(l.p.RenamedCommands, [2]string{args[1], args[2]})
+		return nil
+	}
+
+	e := lookup(name)
+	if e == nil {
+		return errors.New("Bad directive or wrong number of arguments")
+	}
+
+	var arg string
+	if e.flags&flag_multi_arg != 0 {
+		arg = strings.Join(args[1:], " ")
+	} else if len(args) != 2 {
+		return errors.New("wrong number of arguments")
+	} else {
+		arg = args[1]
+	}
+
+	l.touched = append(l.touched, e)
+	return e.value.set(e.ptr(l.p), arg)
+}
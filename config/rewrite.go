@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HwHgoo/Gredis/utils"
+)
+
+const rewrite_signature = "# Generated by CONFIG REWRITE"
+
+// Rewrite writes the running configuration back to the config file the
+// server was started with. Comments, unknown lines and include directives
+// are kept in place, options already in the file are updated where they are
+// and options changed from their defaults are appended at the end.
+func Rewrite() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if configFile == "" {
+		return ErrNoConfigFile
+	}
+
+	content, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	lines := make([]string, 0)
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	}
+
+	p := current.Load()
+	rewritten := make(map[*entry]bool)
+	output := make([]string, 0, len(lines))
+	hasSignature := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == rewrite_signature {
+			hasSignature = true
+		}
+		if trimmed == "" || trimmed[0] == '#' {
+			output = append(output, line)
+			continue
+		}
+
+		args, err := utils.SplitArgs(trimmed)
+		if err != nil || len(args) == 0 {
+			output = append(output, line)
+			continue
+		}
+
+		e := lookup(args[0])
+		if e == nil {
+			// include directives and lines we don't understand
+			output = append(output, line)
+			continue
+		}
+
+		// keep only the first occurrence of an option
+		if rewritten[e] {
+			continue
+		}
+		rewritten[e] = true
+		output = append(output, formatEntry(e, p))
+	}
+
+	for _, e := range entries {
+		if rewritten[e] || e.value.isDefault(e.ptr(p)) {
+			continue
+		}
+		if !hasSignature {
+			output = append(output, rewrite_signature)
+			hasSignature = true
+		}
+		output = append(output, formatEntry(e, p))
+	}
+
+	return writeFileAtomic(configFile, []byte(strings.Join(output, "\n")+"\n"))
+}
+
+func formatEntry(e *entry, p *ServerProperties) string {
+	v := e.value.get(e.ptr(p))
+	if e.flags&flag_multi_arg != 0 && v != "" {
+		fields := strings.Fields(v)
+		for i := range fields {
+			fields[i] = utils.QuoteArg(fields[i])
+		}
+		return e.name + " " + strings.Join(fields, " ")
+	}
+	return e.name + " " + utils.QuoteArg(v)
+}
+
+// write to a temporary file in the same directory and rename it over path,
+// so that a crash never leaves a truncated config file behind
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "redis-rewrite-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,289 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// value is a typed view over a field of ServerProperties,
+// field is a pointer to the field in the snapshot being read or written
+type value interface {
+	set(field any, s string) error
+	get(field any) string
+	isDefault(field any) bool
+	reset(field any)
+}
+
+type boolValue struct {
+	def bool
+}
+
+func (v *boolValue) set(field any, s string) error {
+	switch strings.ToLower(s) {
+	case "yes":
+		*field.(*bool) = true
+	case "no":
+		*field.(*bool) = false
+	default:
+		return errors.New("argument must be 'yes' or 'no'")
+	}
+	return nil
+}
+
+func (v *boolValue) get(field any) string {
+	if *field.(*bool) {
+		return "yes"
+	}
+	return "no"
+}
+
+func (v *boolValue) isDefault(field any) bool { return *field.(*bool) == v.def }
+func (v *boolValue) reset(field any)          { *field.(*bool) = v.def }
+
+type intValue struct {
+	def      int
+	min, max int
+}
+
+func (v *intValue) set(field any, s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New("argument couldn't be parsed into an integer")
+	}
+	if n < v.min || n > v.max {
+		return errors.New("argument must be between " + strconv.Itoa(v.min) + " and " + strconv.Itoa(v.max) + " inclusive")
+	}
+	*field.(*int) = n
+	return nil
+}
+
+func (v *intValue) get(field any) string     { return strconv.Itoa(*field.(*int)) }
+func (v *intValue) isDefault(field any) bool { return *field.(*int) == v.def }
+func (v *intValue) reset(field any)          { *field.(*int) = v.def }
+
+type stringValue struct {
+	def string
+}
+
+func (v *stringValue) set(field any, s string) error {
+	*field.(*string) = s
+	return nil
+}
+
+func (v *stringValue) get(field any) string     { return *field.(*string) }
+func (v *stringValue) isDefault(field any) bool { return *field.(*string) == v.def }
+func (v *stringValue) reset(field any)          { *field.(*string) = v.def }
+
+// stringListValue holds space separated values like `bind 127.0.0.1 ::1`
+type stringListValue struct {
+	def []string
+}
+
+func (v *stringListValue) set(field any, s string) error {
+	*field.(*[]string) = strings.Fields(s)
+	return nil
+}
+
+func (v *stringListValue) get(field any) string {
+	return strings.Join(*field.(*[]string), " ")
+}
+
+func (v *stringListValue) isDefault(field any) bool {
+	return v.get(field) == strings.Join(v.def, " ")
+}
+
+func (v *stringListValue) reset(field any) {
+	*field.(*[]string) = append([]string(nil), v.def...)
+}
+
+// octalValue is an integer written in base 8, like file permissions
+type octalValue struct {
+	def int
+}
+
+func (v *octalValue) set(field any, s string) error {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0777 {
+		return errors.New("argument must be an octal number between 0 and 777")
+	}
+	*field.(*int) = int(n)
+	return nil
+}
+
+func (v *octalValue) get(field any) string     { return strconv.FormatInt(int64(*field.(*int)), 8) }
+func (v *octalValue) isDefault(field any) bool { return *field.(*int) == v.def }
+func (v *octalValue) reset(field any)          { *field.(*int) = v.def }
+
+// enumValue accepts one of a fixed set of words
+type enumValue struct {
+	def     string
+	options []string
+}
+
+func (v *enumValue) set(field any, s string) error {
+	s = strings.ToLower(s)
+	for _, option := range v.options {
+		if s == option {
+			*field.(*string) = s
+			return nil
+		}
+	}
+	return errors.New("argument(s) must be one of the following: " + strings.Join(v.options, ", "))
+}
+
+func (v *enumValue) get(field any) string     { return *field.(*string) }
+func (v *enumValue) isDefault(field any) bool { return *field.(*string) == v.def }
+func (v *enumValue) reset(field any)          { *field.(*string) = v.def }
+
+// dirValue is the working directory of the process, empty until changed
+type dirValue struct{}
+
+func (v *dirValue) set(field any, s string) error {
+	dir, err := filepath.Abs(s)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New(dir + " is not a directory")
+	}
+	*field.(*string) = dir
+	return nil
+}
+
+func (v *dirValue) get(field any) string {
+	if dir := *field.(*string); dir != "" {
+		return dir
+	}
+	wd, _ := os.Getwd()
+	return wd
+}
+
+func (v *dirValue) isDefault(field any) bool { return *field.(*string) == "" }
+func (v *dirValue) reset(field any)          { *field.(*string) = "" }
+
+// parseMemory parses sizes like 1024, 1k, 1kb, 32mb or 1gb into bytes,
+// k/m/g are powers of 1000 and kb/mb/gb are powers of 1024
+func parseMemory(s string) (int64, error) {
+	s = strings.ToLower(s)
+	units := []struct {
+		suffix string
+		mul    int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+		{"b", 1},
+	}
+	mul := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, mul = strings.TrimSuffix(s, unit.suffix), unit.mul
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("argument must be a memory value")
+	}
+	return n * mul, nil
+}
+
+// memoryValue is a size in bytes, it can be set with units like 512mb
+type memoryValue struct {
+	def      int64
+	min, max int64
+}
+
+func (v *memoryValue) set(field any, s string) error {
+	n, err := parseMemory(s)
+	if err != nil {
+		return err
+	}
+	if n < v.min || n > v.max {
+		return errors.New("argument must be between " + strconv.FormatInt(v.min, 10) + " and " + strconv.FormatInt(v.max, 10) + " inclusive")
+	}
+	*field.(*int64) = n
+	return nil
+}
+
+func (v *memoryValue) get(field any) string     { return strconv.FormatInt(*field.(*int64), 10) }
+func (v *memoryValue) isDefault(field any) bool { return *field.(*int64) == v.def }
+func (v *memoryValue) reset(field any)          { *field.(*int64) = v.def }
+
+type ClientBufferLimit struct {
+	Hard        int64
+	Soft        int64
+	SoftSeconds int
+}
+
+const (
+	ClientClassNormal = iota
+	ClientClassReplica
+	ClientClassPubSub
+	ClientClassCount
+)
+
+var clientClassNames = [ClientClassCount]string{"normal", "slave", "pubsub"}
+
+// clientBufferLimitValue holds `<class> <hard> <soft> <soft seconds>` groups,
+// classes not mentioned keep their limits
+type clientBufferLimitValue struct {
+	def [ClientClassCount]ClientBufferLimit
+}
+
+func (v *clientBufferLimitValue) set(field any, s string) error {
+	args := strings.Fields(s)
+	if len(args)%4 != 0 {
+		return errors.New("Wrong number of arguments in buffer limit configuration.")
+	}
+
+	limits := *field.(*[ClientClassCount]ClientBufferLimit)
+	for i := 0; i < len(args); i += 4 {
+		class := -1
+		switch strings.ToLower(args[i]) {
+		case "normal":
+			class = ClientClassNormal
+		case "replica", "slave":
+			class = ClientClassReplica
+		case "pubsub":
+			class = ClientClassPubSub
+		default:
+			return errors.New("Invalid client class specified in buffer limit configuration.")
+		}
+
+		hard, err1 := parseMemory(args[i+1])
+		soft, err2 := parseMemory(args[i+2])
+		seconds, err3 := strconv.Atoi(args[i+3])
+		if err1 != nil || err2 != nil || err3 != nil || seconds < 0 {
+			return errors.New("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+		}
+		limits[class] = ClientBufferLimit{Hard: hard, Soft: soft, SoftSeconds: seconds}
+	}
+	*field.(*[ClientClassCount]ClientBufferLimit) = limits
+	return nil
+}
+
+func (v *clientBufferLimitValue) get(field any) string {
+	limits := field.(*[ClientClassCount]ClientBufferLimit)
+	groups := make([]string, 0, ClientClassCount)
+	for class, limit := range limits {
+		groups = append(groups, clientClassNames[class]+" "+strconv.FormatInt(limit.Hard, 10)+" "+
+			strconv.FormatInt(limit.Soft, 10)+" "+strconv.Itoa(limit.SoftSeconds))
+	}
+	return strings.Join(groups, " ")
+}
+
+func (v *clientBufferLimitValue) isDefault(field any) bool {
+	return *field.(*[ClientClassCount]ClientBufferLimit) == v.def
+}
+
+func (v *clientBufferLimitValue) reset(field any) {
+	*field.(*[ClientClassCount]ClientBufferLimit) = v.def
+}
@@ -1,29 +1,389 @@
 package connection
 
-import "net"
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// largest buffer kept to buffer the next replies once written
+const max_reply_buffer_size = 64 * 1024
+
+const (
+	// subscribed to channels, waits for messages instead of commands
+	FlagPubSub = 1 << iota
+	// blocked by a command like BLPOP
+	FlagBlocked
+	// a replica receiving the replication stream
+	FlagReplica
+	// closed once the reply to the current command is written
+	FlagCloseAfterReply
+	// CLIENT NO-EVICT, excluded from client eviction
+	FlagNoEvict
+	// CLIENT NO-TOUCH, reads don't update the access time of keys
+	FlagNoTouch
+)
+
+// reply modes set by CLIENT REPLY
+const (
+	ReplyOn = iota
+	ReplyOff
+	// the reply to the next command is skipped
+	ReplySkip
+)
+
+var nextId atomic.Uint64
+
+var ErrOutputBufferLimit = errors.New("client output buffer limit reached")
 
 type Connection struct {
 	conn       net.Conn
-	selectedDb int
+	id         uint64
+	createTime time.Time
+	selectedDb atomic.Int32
+
+	name        atomic.Pointer[string]
+	libName     atomic.Pointer[string]
+	libVer      atomic.Pointer[string]
+	lastCommand atomic.Pointer[string]
+	// size of the data read from the socket and not parsed yet
+	queryBuffer atomic.Int64
+
+	reply     replyWriter
+	writeLock sync.Mutex
+	// replies accepted by Write and not written to the socket yet. They
+	// build up while the commands of a pipeline are executed.
+	out []byte
+	// size of the replies accepted by Write and not written to the socket yet
+	pending atomic.Int64
+	// unix nano time since the soft limit has been exceeded, 0 if it is not
+	softLimitSince atomic.Int64
+	limitReached   atomic.Bool
+
+	flags     atomic.Int32
+	replyMode atomic.Int32
+	// RESP version negotiated by HELLO
+	protover atomic.Int32
+	closed   atomic.Bool
+	// unix nano time of the last command received
+	lastInteraction atomic.Int64
 }
 
 func MakeConnection(conn net.Conn) *Connection {
-	return &Connection{conn: conn}
+	c := &Connection{
+		conn:       conn,
+		id:         nextId.Add(1),
+		createTime: time.Now(),
+	}
+	c.reply.c = c
+	c.protover.Store(protocol.RESP2)
+	c.UpdateLastInteraction()
+	return c
 }
 
+// Write buffers a reply, replies are written to the socket by Flush. The
+// connection is closed if the reply makes it exceed its output buffer limits.
 func (c *Connection) Write(data []byte) error {
-	_, err := c.conn.Write(data)
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.reply.size = int64(len(c.out))
+	_, err := c.reply.Write(data)
 	return err
 }
 
+// WriteMessage is like Write, but streams the message encoded with
+// the protocol version of the connection
+func (c *Connection) WriteMessage(msg protocol.RedisMessage) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.reply.size = int64(len(c.out))
+	return protocol.WriteMessage(&c.reply, msg, c.Protocol())
+}
+
+// replyWriter buffers a reply, checking the output buffer limits
+// as it grows. It is only used with the write lock held.
+type replyWriter struct {
+	c *Connection
+	// size of the reply so far and of the replies buffered before it
+	size int64
+}
+
+func (w *replyWriter) Write(p []byte) (int, error) {
+	c := w.c
+	if c.IsClosed() {
+		return 0, net.ErrClosed
+	}
+	w.size += int64(len(p))
+	if c.checkOutputBufferLimits(w.size) {
+		c.closeForOutputBufferLimit()
+		return 0, ErrOutputBufferLimit
+	}
+	c.out = append(c.out, p...)
+	c.pending.Store(w.size)
+	return len(p), nil
+}
+
+// Flush writes the buffered replies to the socket. It is called before
+// the connection waits, for the next commands or e.g. for a pause to end,
+// so that the replies of the commands pipelined before are not held.
+// While the socket is blocked, the buffered replies are still accounted
+// for by CheckOutputBufferLimits, which closes a client too slow to read.
+func (c *Connection) Flush() error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if len(c.out) == 0 {
+		return nil
+	}
+	if c.IsClosed() {
+		return net.ErrClosed
+	}
+
+	_, err := c.conn.Write(c.out)
+	// a buffer grown by large replies is not kept
+	if cap(c.out) > max_reply_buffer_size {
+		c.out = nil
+	} else {
+		c.out = c.out[:0]
+	}
+	c.pending.Store(0)
+	if err != nil {
+		c.Close()
+	}
+	return err
+}
+
+// Class returns the client class used to pick the output buffer limits
+func (c *Connection) Class() int {
+	switch {
+	case c.HasFlags(FlagReplica):
+		return config.ClientClassReplica
+	case c.HasFlags(FlagPubSub):
+		return config.ClientClassPubSub
+	default:
+		return config.ClientClassNormal
+	}
+}
+
+// CheckOutputBufferLimits closes the connection if the replies it could not
+// write to the socket yet exceed its limits. It is called periodically so
+// that a client too slow to read is closed while a write is blocked.
+func (c *Connection) CheckOutputBufferLimits() bool {
+	if c.IsClosed() || !c.checkOutputBufferLimits(c.pending.Load()) {
+		return false
+	}
+	c.closeForOutputBufferLimit()
+	return true
+}
+
+// checkOutputBufferLimits reports whether size exceeds the hard limit, or
+// has exceeded the soft limit for longer than its soft seconds
+func (c *Connection) checkOutputBufferLimits(size int64) bool {
+	limit := config.Properties().ClientOutputBufferLimits[c.Class()]
+	if limit.Hard > 0 && size >= limit.Hard {
+		return true
+	}
+	if limit.Soft == 0 || size < limit.Soft {
+		c.softLimitSince.Store(0)
+		return false
+	}
+
+	now := time.Now().UnixNano()
+	if c.softLimitSince.CompareAndSwap(0, now) {
+		return false
+	}
+	return time.Duration(now-c.softLimitSince.Load()) > time.Duration(limit.SoftSeconds)*time.Second
+}
+
+func (c *Connection) closeForOutputBufferLimit() {
+	if c.limitReached.CompareAndSwap(false, true) {
+		log.Println("closing client", c.conn.RemoteAddr(), "for overcoming of output buffer limits")
+	}
+	c.Close()
+}
+
+// OutputBufferLimitReached reports whether the connection was closed
+// for exceeding its output buffer limits
+func (c *Connection) OutputBufferLimitReached() bool {
+	return c.limitReached.Load()
+}
+
+// Buffered returns the size of replies not written to the socket yet
+func (c *Connection) Buffered() int {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return len(c.out)
+}
+
+// OutputBufferSize returns the size of the replies not written to the socket yet
+func (c *Connection) OutputBufferSize() int {
+	return int(c.pending.Load())
+}
+
+// Read reads from the socket. Buffered replies are flushed first, as the
+// client may wait for them before sending more, so that a pipeline is
+// answered with as few writes as possible.
+func (c *Connection) Read(p []byte) (int, error) {
+	if err := c.Flush(); err != nil {
+		return 0, err
+	}
+	return c.conn.Read(p)
+}
+
+func (c *Connection) SetQueryBufferSize(size int) {
+	c.queryBuffer.Store(int64(size))
+}
+
+func (c *Connection) QueryBufferSize() int {
+	return int(c.queryBuffer.Load())
+}
+
 func (c *Connection) GetSelectedDb() int {
-	return c.selectedDb
+	return int(c.selectedDb.Load())
 }
 
 func (c *Connection) SelectDb(db int) {
-	c.selectedDb = db
+	c.selectedDb.Store(int32(db))
+}
+
+// ID is unique among all the connections of the process
+func (c *Connection) ID() uint64 {
+	return c.id
+}
+
+// Addr returns the address of the client, unix socket clients
+// are identified by the socket path
+func (c *Connection) Addr() string {
+	if c.conn.LocalAddr().Network() == "unix" {
+		return c.conn.LocalAddr().String() + ":0"
+	}
+	return c.conn.RemoteAddr().String()
+}
+
+// LocalAddr returns the address the client connected to
+func (c *Connection) LocalAddr() string {
+	if c.conn.LocalAddr().Network() == "unix" {
+		return c.conn.LocalAddr().String() + ":0"
+	}
+	return c.conn.LocalAddr().String()
+}
+
+// Age returns how long ago the connection was accepted
+func (c *Connection) Age() time.Duration {
+	return time.Since(c.createTime)
+}
+
+func loadString(p *atomic.Pointer[string]) string {
+	if s := p.Load(); s != nil {
+		return *s
+	}
+	return ""
+}
+
+func (c *Connection) Name() string {
+	return loadString(&c.name)
+}
+
+func (c *Connection) SetName(name string) {
+	c.name.Store(&name)
+}
+
+func (c *Connection) LibName() string {
+	return loadString(&c.libName)
+}
+
+func (c *Connection) SetLibName(name string) {
+	c.libName.Store(&name)
+}
+
+func (c *Connection) LibVersion() string {
+	return loadString(&c.libVer)
+}
+
+func (c *Connection) SetLibVersion(version string) {
+	c.libVer.Store(&version)
+}
+
+// LastCommand returns the name of the last command executed, or
+// an empty string if none was
+func (c *Connection) LastCommand() string {
+	return loadString(&c.lastCommand)
+}
+
+func (c *Connection) SetLastCommand(name string) {
+	c.lastCommand.Store(&name)
+}
+
+func (c *Connection) SetFlags(flags int) {
+	for {
+		old := c.flags.Load()
+		if c.flags.CompareAndSwap(old, old|int32(flags)) {
+			return
+		}
+	}
+}
+
+func (c *Connection) ClearFlags(flags int) {
+	for {
+		old := c.flags.Load()
+		if c.flags.CompareAndSwap(old, old&^int32(flags)) {
+			return
+		}
+	}
+}
+
+// HasFlags reports whether any of the given flags is set
+func (c *Connection) HasFlags(flags int) bool {
+	return c.flags.Load()&int32(flags) != 0
+}
+
+// Protocol returns the RESP version replies are encoded with
+func (c *Connection) Protocol() int {
+	return int(c.protover.Load())
+}
+
+func (c *Connection) SetProtocol(protover int) {
+	c.protover.Store(int32(protover))
+}
+
+func (c *Connection) NoTouch() bool {
+	return c.HasFlags(FlagNoTouch)
+}
+
+func (c *Connection) ReplyMode() int {
+	return int(c.replyMode.Load())
+}
+
+func (c *Connection) SetReplyMode(mode int) {
+	c.replyMode.Store(int32(mode))
+}
+
+// TakeReplySkip reports whether the reply to the command about to be
+// executed must be skipped, the connection replies again afterwards
+func (c *Connection) TakeReplySkip() bool {
+	return c.replyMode.CompareAndSwap(ReplySkip, ReplyOn)
+}
+
+func (c *Connection) UpdateLastInteraction() {
+	c.lastInteraction.Store(time.Now().UnixNano())
+}
+
+// IdleTime returns how long ago the last command was received
+func (c *Connection) IdleTime() time.Duration {
+	return time.Since(time.Unix(0, c.lastInteraction.Load()))
 }
 
 func (c *Connection) Close() {
-	c.conn.Close()
+	if c.closed.CompareAndSwap(false, true) {
+		c.conn.Close()
+	}
+}
+
+func (c *Connection) IsClosed() bool {
+	return c.closed.Load()
 }
@@ -0,0 +1,201 @@
+package connection
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/connection/connectiontest"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// loopback returns the two ends of a tcp connection, everything
+// written to the first one is read and discarded by the second one
+func loopback(b *testing.B) (*connectiontest.CountingConn, net.Conn) {
+	server, client := connectiontest.Loopback(b)
+	go io.Copy(io.Discard, client)
+	return server, client
+}
+
+var reply = []byte("$11\r\nhello world\r\n")
+
+func benchmarkPipelineReplies(b *testing.B, pipeline int, buffered bool) {
+	server, client := loopback(b)
+	defer client.Close()
+	defer server.Close()
+	c := MakeConnection(server)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < pipeline; j++ {
+			if buffered {
+				c.Write(reply)
+			} else {
+				server.Write(reply)
+			}
+		}
+		c.Flush()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(server.Writes.Load())/float64(b.N), "writes/op")
+}
+
+// Replies to a pipeline written one by one, as the handler used to,
+// compared with replies buffered and flushed once per pipeline
+func BenchmarkPipelineReplies(b *testing.B) {
+	for _, pipeline := range []int{1, 100, 1000} {
+		b.Run("unbuffered/"+strconv.Itoa(pipeline), func(b *testing.B) { benchmarkPipelineReplies(b, pipeline, false) })
+		b.Run("buffered/"+strconv.Itoa(pipeline), func(b *testing.B) { benchmarkPipelineReplies(b, pipeline, true) })
+	}
+}
+
+func TestBufferedWrite(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	c := MakeConnection(server)
+	defer c.Close()
+
+	if err := c.Write(reply); err != nil {
+		t.Fatal(err)
+	}
+	if c.Buffered() != len(reply) {
+		t.Fatalf("expected %d buffered bytes, got %d", len(reply), c.Buffered())
+	}
+
+	go c.Flush()
+	buf := make([]byte, len(reply))
+	if _, err := io.ReadFull(client, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != string(reply) {
+		t.Fatalf("unexpected reply %q", buf)
+	}
+}
+
+func setOutputBufferLimit(t *testing.T, limit string) {
+	if err := config.Set([][2]string{{"client-output-buffer-limit", limit}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { config.Set([][2]string{{"client-output-buffer-limit", "normal 0 0 0 pubsub 32mb 8mb 60"}}) })
+}
+
+func TestOutputBufferHardLimit(t *testing.T) {
+	setOutputBufferLimit(t, "normal 64 0 0")
+	server, client := net.Pipe()
+	defer client.Close()
+	c := MakeConnection(server)
+
+	if err := c.Write(reply); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(make([]byte, 64)); err != ErrOutputBufferLimit {
+		t.Fatalf("expected output buffer limit error, got %v", err)
+	}
+	if !c.IsClosed() || !c.OutputBufferLimitReached() {
+		t.Fatal("connection should be closed")
+	}
+}
+
+// writeUntilError writes small replies, as to the commands of a pipeline
+func writeUntilError(c *Connection, max int) error {
+	for i := 0; i < max; i++ {
+		if err := c.Write(reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestOutputBufferHardLimitPipeline(t *testing.T) {
+	setOutputBufferLimit(t, "normal 4kb 0 0")
+	server, client := net.Pipe()
+	defer client.Close()
+	c := MakeConnection(server)
+
+	// the replies build up until the pipeline is executed
+	if err := writeUntilError(c, 1000); err != ErrOutputBufferLimit {
+		t.Fatalf("expected output buffer limit error, got %v", err)
+	}
+	if !c.IsClosed() || !c.OutputBufferLimitReached() {
+		t.Fatal("connection should be closed")
+	}
+}
+
+func TestOutputBufferSoftLimit(t *testing.T) {
+	setOutputBufferLimit(t, "pubsub 0 1k 1")
+	server, client := net.Pipe()
+	defer client.Close()
+	c := MakeConnection(server)
+	c.SetFlags(FlagPubSub)
+
+	for c.OutputBufferSize() < 1024 {
+		if err := writeUntilError(c, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.CheckOutputBufferLimits() {
+		t.Fatal("soft limit should not be reached right away")
+	}
+	if err := writeUntilError(c, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if !c.CheckOutputBufferLimits() {
+		t.Fatal("soft limit should be reached")
+	}
+	if !c.OutputBufferLimitReached() {
+		t.Fatal("connection should be closed for its output buffer limit")
+	}
+	if err := c.Write(reply); err == nil {
+		t.Fatal("writes should fail once the connection is closed")
+	}
+}
+
+func TestOutputBufferLimitBlockedWrite(t *testing.T) {
+	setOutputBufferLimit(t, "normal 0 1k 1")
+	server, client := net.Pipe()
+	defer client.Close()
+	c := MakeConnection(server)
+
+	if err := c.Write(make([]byte, 2048)); err != nil {
+		t.Fatal(err)
+	}
+	// nobody reads the client side, the write blocks
+	flushed := make(chan error)
+	go func() { flushed <- c.Flush() }()
+
+	time.Sleep(1100 * time.Millisecond)
+	if !c.CheckOutputBufferLimits() {
+		t.Fatal("soft limit should be reached")
+	}
+	if err := <-flushed; err == nil {
+		t.Fatal("the blocked write should fail once the connection is closed")
+	}
+}
+
+func TestOutputBufferLimitStreamed(t *testing.T) {
+	setOutputBufferLimit(t, "normal 64kb 0 0")
+	server, client := net.Pipe()
+	defer client.Close()
+	go io.Copy(io.Discard, client)
+	c := MakeConnection(server)
+
+	// each element is below the limit, the whole reply is not
+	elements := make([]protocol.RedisMessage, 100)
+	for i := range elements {
+		elements[i] = protocol.MakeBulkString(make([]byte, 1024))
+	}
+	if err := c.WriteMessage(protocol.MakeArray(elements[:10])); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteMessage(protocol.MakeArray(elements)); err != ErrOutputBufferLimit {
+		t.Fatalf("expected output buffer limit error, got %v", err)
+	}
+	if !c.OutputBufferLimitReached() {
+		t.Fatal("connection should be closed for its output buffer limit")
+	}
+}
@@ -0,0 +1,44 @@
+// Package connectiontest provides utilities for testing what reaches
+// the socket of a connection.
+package connectiontest
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+// CountingConn counts the writes reaching the socket, each one is a syscall
+type CountingConn struct {
+	net.Conn
+	Writes atomic.Int64
+}
+
+func (c *CountingConn) Write(b []byte) (int, error) {
+	c.Writes.Add(1)
+	return c.Conn.Write(b)
+}
+
+// Loopback returns the two ends of a tcp connection, the writes
+// to the first one are counted
+func Loopback(tb testing.TB) (*CountingConn, net.Conn) {
+	lsn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer lsn.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := lsn.Accept()
+		if err != nil {
+			tb.Error(err)
+		}
+		accepted <- conn
+	}()
+	client, err := net.Dial("tcp", lsn.Addr().String())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return &CountingConn{Conn: <-accepted}, client
+}
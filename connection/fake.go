@@ -0,0 +1,30 @@
+package connection
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// MakeFakeConnection makes a connection without socket, for commands
+// executed in process whose replies are returned instead of being written
+func MakeFakeConnection() *Connection {
+	return MakeConnection(fakeConn{})
+}
+
+// fakeConn reads nothing and discards what is written
+type fakeConn struct{}
+
+type fakeAddr struct{}
+
+func (fakeAddr) Network() string { return "fake" }
+func (fakeAddr) String() string  { return "fake" }
+
+func (fakeConn) Read(p []byte) (int, error)         { return 0, io.EOF }
+func (fakeConn) Write(p []byte) (int, error)        { return len(p), nil }
+func (fakeConn) Close() error                       { return nil }
+func (fakeConn) LocalAddr() net.Addr                { return fakeAddr{} }
+func (fakeConn) RemoteAddr() net.Addr               { return fakeAddr{} }
+func (fakeConn) SetDeadline(t time.Time) error      { return nil }
+func (fakeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (fakeConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,62 @@
+package connection
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// Registry keeps track of the connected clients by ID
+type Registry struct {
+	clients map[uint64]*Connection
+	lock    sync.RWMutex
+}
+
+func MakeRegistry() *Registry {
+	return &Registry{clients: make(map[uint64]*Connection)}
+}
+
+// Add registers a client unless max clients are connected already
+func (r *Registry) Add(c *Connection, max int) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.clients) >= max {
+		return false
+	}
+	r.clients[c.id] = c
+	return true
+}
+
+func (r *Registry) Remove(c *Connection) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.clients, c.id)
+}
+
+// Get returns the client with the given ID, or nil
+func (r *Registry) Get(id uint64) *Connection {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.clients[id]
+}
+
+func (r *Registry) Len() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.clients)
+}
+
+// List returns the clients ordered by ID, that is by connection time
+func (r *Registry) List() []*Connection {
+	r.lock.RLock()
+	clients := make([]*Connection, 0, len(r.clients))
+	for _, c := range r.clients {
+		clients = append(clients, c)
+	}
+	r.lock.RUnlock()
+
+	slices.SortFunc(clients, func(a, b *Connection) int {
+		return cmp.Compare(a.id, b.id)
+	})
+	return clients
+}
@@ -1,82 +1,298 @@
 package command
 
 import (
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
 	"github.com/HwHgoo/Gredis/connection"
 	"github.com/HwHgoo/Gredis/core/interface/redis"
 	"github.com/HwHgoo/Gredis/core/protocol"
 )
 
 type DatabaseCommandExecutor func(db redis.DB, args [][]byte) protocol.RedisMessage
-type ServerCommandExecutor func(conn *connection.Connection, args [][]byte) protocol.RedisMessage
+type ServerCommandExecutor func(server redis.Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage
 
 type CommandExecutor interface {
 	DatabaseCommandExecutor | ServerCommandExecutor
 }
 
 type Command[T CommandExecutor] struct {
-	name string
-	// including command itself
-	// positive arity means exact number of arguments
-	// negative arity means at least abs(arity) arguments
-	arity int
-	exec  T
+	*Spec
+	exec T
 }
 
+// guards the registry: commands are registered in init, and while
+// servers are running when they load modules
+var lock sync.RWMutex
+
+// executors by full name, e.g. get or client|list
 var dbCommands = make(map[string]*Command[DatabaseCommandExecutor])
 var serverCommands = make(map[string]*Command[ServerCommandExecutor])
 
-func Register[T CommandExecutor](name string, arity int, exec T) {
+// specs of the top level commands, whatever their executor
+var specs = make(map[string]*Spec)
+
+// Register registers a command. The executor of a container command
+// is only called without subcommand, it may be nil if its arity
+// requires one.
+func Register[T CommandExecutor](spec Spec, exec T) {
+	lock.Lock()
+	defer lock.Unlock()
+	s := &spec
+	s.Categories |= implicitCategories(s.Flags)
+	addExecutor(s, exec)
+	specs[s.Name] = s
+}
+
+// RegisterSubcommand registers a subcommand of a command registered with the
+// same executor type. Its arity counts both the command and subcommand names.
+// A HELP subcommand listing the others is added along the first one.
+func RegisterSubcommand[T CommandExecutor](parent string, spec Spec, exec T) {
+	lock.Lock()
+	defer lock.Unlock()
+	registerSubcommand(parent, spec, exec)
+}
+
+func registerSubcommand[T CommandExecutor](parent string, spec Spec, exec T) {
+	p := specs[parent]
+	if p == nil {
+		panic("unknown command " + parent)
+	}
+	if len(p.subcommands) == 0 && spec.Name != "help" {
+		registerSubcommand(parent, Spec{
+			Name: "help", Arity: 2, Flags: FlagLoading | FlagStale, Categories: p.Categories,
+			Help:    []string{"HELP", "    Print this help."},
+			Summary: "Returns helpful text about the different subcommands.",
+			Since:   "5.0.0", Group: p.Group, Complexity: "O(1)",
+		}, helpExecutor[T](p))
+	}
+
+	s := &spec
+	s.Categories |= implicitCategories(s.Flags)
+	s.parent = p
+	addExecutor(s, exec)
+	p.subcommands = slices.DeleteFunc(p.subcommands, func(sub *Spec) bool { return sub.Name == s.Name })
+	p.subcommands = append(p.subcommands, s)
+}
+
+func addExecutor[T CommandExecutor](s *Spec, exec T) {
+	s.stats = &Stats{}
 	switch executer := any(exec).(type) {
 	case DatabaseCommandExecutor:
-		dbCommands[name] = &Command[DatabaseCommandExecutor]{name, arity, executer}
+		dbCommands[s.FullName()] = &Command[DatabaseCommandExecutor]{s, executer}
 	case ServerCommandExecutor:
-		serverCommands[name] = &Command[ServerCommandExecutor]{name, arity, executer}
+		serverCommands[s.FullName()] = &Command[ServerCommandExecutor]{s, executer}
 	default:
 		panic("unknown executer type")
 	}
 }
 
-func init() {
+// helpExecutor returns an executor replying with the HELP of the command
+func helpExecutor[T CommandExecutor](spec *Spec) T {
+	var exec any
+	switch any(*new(T)).(type) {
+	case DatabaseCommandExecutor:
+		exec = DatabaseCommandExecutor(func(redis.DB, [][]byte) protocol.RedisMessage {
+			return MakeHelpReply(spec.HelpLines())
+		})
+	case ServerCommandExecutor:
+		exec = ServerCommandExecutor(func(redis.Server, *connection.Connection, [][]byte) protocol.RedisMessage {
+			return MakeHelpReply(spec.HelpLines())
+		})
+	}
+	return exec.(T)
+}
+
+// DatabaseExecutor adapts exec, which takes the database as its concrete
+// type, to the registry. A nil exec stays nil for container commands,
+// which are never called without subcommand.
+func DatabaseExecutor[D any, A ~[][]byte](exec func(D, A) protocol.RedisMessage) DatabaseCommandExecutor {
+	if exec == nil {
+		return nil
+	}
+	return func(db redis.DB, args [][]byte) protocol.RedisMessage {
+		return exec(db.(D), A(args))
+	}
+}
+
+// ServerExecutor is DatabaseExecutor for server commands
+func ServerExecutor[S any](exec func(S, *connection.Connection, [][]byte) protocol.RedisMessage) ServerCommandExecutor {
+	if exec == nil {
+		return nil
+	}
+	return func(server redis.Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+		return exec(server.(S), conn, args)
+	}
+}
+
+func MakeHelpReply(lines []string) protocol.RedisMessage {
+	elements := make([]protocol.RedisMessage, len(lines))
+	for i, line := range lines {
+		elements[i] = protocol.MakeSimpleString([]byte(line))
+	}
+	return protocol.MakeArray(elements)
 }
 
 func IsServerCommand(name string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
 	_, ok := serverCommands[name]
 	return ok
 }
 
 func IsDbCommand(name string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
 	_, ok := dbCommands[name]
 	return ok
 }
 
 // return true if command exists
 func Exists(name string) bool {
-	_, ok := serverCommands[name]
-	if ok {
-		return true
+	lock.RLock()
+	defer lock.RUnlock()
+	_, ok := specs[name]
+	return ok
+}
+
+// Lookup returns the spec of the command, or nil if it doesn't exist
+func Lookup(name string) *Spec {
+	lock.RLock()
+	defer lock.RUnlock()
+	return specs[name]
+}
+
+// Find returns the spec of the command, or subcommand, invoked by args.
+// The reply is an error if it doesn't exist or the arity doesn't match,
+// the spec being still returned in the latter case.
+func Find(args [][]byte) (*Spec, protocol.RedisErrorMessage) {
+	name := strings.ToLower(string(args[0]))
+	lock.RLock()
+	defer lock.RUnlock()
+	spec := specs[name]
+	if spec == nil {
+		return nil, MakeUnknownCommandError(args)
 	}
 
-	_, ok = dbCommands[name]
-	return ok
+	if len(spec.subcommands) > 0 && len(args) > 1 {
+		sub := spec.subcommand(strings.ToLower(string(args[1])))
+		if sub == nil {
+			return nil, protocol.MakeUnknownSubcommandError(name, string(args[1]))
+		}
+		spec = sub
+	}
+	if !spec.ValidateArity(args) {
+		return spec, protocol.MakeWrongNumberOfArgError(spec.FullName())
+	}
+	return spec, nil
 }
 
-func ExecServerCommand(name string, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
-	cmd := serverCommands[name]
-	return cmd.exec(conn, args)
+// Rename renames a command along with its subcommands, an empty
+// name disables it. It is meant to be called before serving clients.
+func Rename(name, newName string) error {
+	name, newName = strings.ToLower(name), strings.ToLower(newName)
+	lock.Lock()
+	defer lock.Unlock()
+	spec := specs[name]
+	if spec == nil {
+		return errors.New("No such command '" + name + "' in rename-command")
+	}
+	if newName != "" && specs[newName] != nil {
+		return errors.New("Target command name '" + newName + "' already exists")
+	}
+
+	delete(specs, name)
+	executors := make([]any, 0, 1+len(spec.subcommands))
+	for _, s := range append([]*Spec{spec}, spec.subcommands...) {
+		executors = append(executors, removeExecutor(s.FullName()))
+	}
+	if newName == "" {
+		return nil
+	}
+
+	spec.Name = newName
+	specs[newName] = spec
+	for i, s := range append([]*Spec{spec}, spec.subcommands...) {
+		switch cmd := executors[i].(type) {
+		case *Command[DatabaseCommandExecutor]:
+			dbCommands[s.FullName()] = cmd
+		case *Command[ServerCommandExecutor]:
+			serverCommands[s.FullName()] = cmd
+		}
+	}
+	return nil
 }
 
-func ExecDatabaseCommand(name string, db redis.DB, args [][]byte) protocol.RedisMessage {
-	cmd := dbCommands[name]
-	return cmd.exec(db, args)
+// removeExecutor removes the executor of the command and returns it
+func removeExecutor(fullName string) any {
+	if cmd, ok := dbCommands[fullName]; ok {
+		delete(dbCommands, fullName)
+		return cmd
+	}
+	if cmd, ok := serverCommands[fullName]; ok {
+		delete(serverCommands, fullName)
+		return cmd
+	}
+	return nil
 }
 
-func ValidateArity(name string, args [][]byte) bool {
-	var arity int
-	if cmd := serverCommands[name]; cmd != nil {
-		arity = cmd.arity
-	} else if cmd := dbCommands[name]; cmd != nil {
-		arity = cmd.arity
+// MakeUnknownCommandError returns the error replied to args naming no command
+func MakeUnknownCommandError(args [][]byte) protocol.RedisErrorMessage {
+	return protocol.MakeUnknownCommandError(strings.ToLower(string(args[0])), argStartWith(args[1:]))
+}
+
+func argStartWith(args [][]byte) string {
+	if len(args) == 0 || len(args[0]) == 0 {
+		return ""
+	}
+
+	return string(args[0][0])
+}
+
+// List returns the specs of all the commands sorted by name
+func List() []*Spec {
+	lock.RLock()
+	defer lock.RUnlock()
+	list := make([]*Spec, 0, len(specs))
+	for _, spec := range specs {
+		list = append(list, spec)
+	}
+	slices.SortFunc(list, func(a, b *Spec) int { return cmp.Compare(a.Name, b.Name) })
+	return list
+}
+
+// Count returns the number of commands, subcommands excluded
+func Count() int {
+	lock.RLock()
+	defer lock.RUnlock()
+	return len(specs)
+}
+
+// HasFlags reports whether the command has any of the given flags
+func HasFlags(name string, flags int) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	if spec := specs[name]; spec != nil {
+		return spec.Flags&flags != 0
 	}
-	return (arity > 0 && len(args) == arity) ||
-		(arity < 0 && len(args) >= -arity)
+	return false
+}
+
+// ExecServerCommand executes the command found for args,
+// with the arguments following the command name
+func ExecServerCommand(spec *Spec, server redis.Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	lock.RLock()
+	cmd := serverCommands[spec.FullName()]
+	lock.RUnlock()
+	return cmd.exec(server, conn, args[spec.depth():])
+}
+
+func ExecDatabaseCommand(spec *Spec, db redis.DB, args [][]byte) protocol.RedisMessage {
+	lock.RLock()
+	cmd := dbCommands[spec.FullName()]
+	lock.RUnlock()
+	return cmd.exec(db, args[spec.depth():])
 }
@@ -0,0 +1,279 @@
+package command
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// command flags
+const (
+	// may modify the dataset
+	FlagWrite = 1 << iota
+	// only reads the dataset
+	FlagReadOnly
+	// may increase memory usage, refused when out of memory
+	FlagDenyOOM
+	// administrative command, e.g. CONFIG or SHUTDOWN
+	FlagAdmin
+	// pub/sub related command
+	FlagPubSub
+	// not allowed in scripts
+	FlagNoScript
+	// allowed while the dataset is loading
+	FlagLoading
+	// allowed on a replica with stale data
+	FlagStale
+	// O(1) or O(log(N)) command which never blocks
+	FlagFast
+)
+
+var flagNames = []string{"write", "readonly", "denyoom", "admin", "pubsub", "noscript", "loading", "stale", "fast"}
+
+// ACL categories
+const (
+	CategoryKeyspace = 1 << iota
+	CategoryRead
+	CategoryWrite
+	CategorySet
+	CategorySortedSet
+	CategoryList
+	CategoryHash
+	CategoryString
+	CategoryBitmap
+	CategoryHyperLogLog
+	CategoryGeo
+	CategoryStream
+	CategoryPubSub
+	CategoryAdmin
+	CategoryFast
+	CategorySlow
+	CategoryBlocking
+	CategoryDangerous
+	CategoryConnection
+	CategoryTransaction
+	CategoryScripting
+)
+
+var categoryNames = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string", "bitmap", "hyperloglog",
+	"geo", "stream", "pubsub", "admin", "fast", "slow", "blocking", "dangerous", "connection", "transaction", "scripting",
+}
+
+// CategoryNames returns the names of all the ACL categories
+func CategoryNames() []string {
+	return categoryNames
+}
+
+// implicitCategories returns the categories implied by the flags,
+// e.g. every command is either @fast or @slow
+func implicitCategories(flags int) int {
+	categories := 0
+	if flags&FlagWrite != 0 {
+		categories |= CategoryWrite
+	}
+	if flags&FlagReadOnly != 0 {
+		categories |= CategoryRead
+	}
+	if flags&FlagAdmin != 0 {
+		categories |= CategoryAdmin | CategoryDangerous
+	}
+	if flags&FlagPubSub != 0 {
+		categories |= CategoryPubSub
+	}
+	if flags&FlagFast != 0 {
+		categories |= CategoryFast
+	} else {
+		categories |= CategorySlow
+	}
+	return categories
+}
+
+// key spec flags
+const (
+	// the key is only read
+	KeyRO = 1 << iota
+	// the key is read and written
+	KeyRW
+	// the key is overwritten, its value is not read
+	KeyOW
+	// the key is removed
+	KeyRM
+	// the value is returned or otherwise exposed to the user
+	KeyAccess
+	// the value is updated
+	KeyUpdate
+	// data is added to the value, and none is removed
+	KeyInsert
+	// data is removed from the value
+	KeyDelete
+)
+
+var keyFlagNames = []string{"RO", "RW", "OW", "RM", "access", "update", "insert", "delete"}
+
+// KeySpec locates a range of keys in the arguments
+type KeySpec struct {
+	Flags int
+	// index of the first key, the command name being 0
+	Index int
+	// index of the last key relative to Index, negative values
+	// count from the end of the arguments, -1 being the last one
+	LastKey int
+	// distance between keys, e.g. 2 for key value pairs, 1 if 0
+	Step int
+}
+
+// Spec describes a command for execution and introspection
+type Spec struct {
+	Name string
+	// including command itself
+	// positive arity means exact number of arguments
+	// negative arity means at least abs(arity) arguments
+	Arity      int
+	Flags      int
+	Categories int
+	Keys       []KeySpec
+
+	// documentation returned by COMMAND DOCS
+	Summary    string
+	Since      string
+	Group      string
+	Complexity string
+	// name of the module which created the command, if any
+	Module string
+	// usage and description of a subcommand in the HELP of its command.
+	// For a command, the description of its use without subcommand.
+	Help []string
+
+	parent *Spec
+	// in registration order, which is the order of HELP
+	subcommands []*Spec
+	stats       *Stats
+}
+
+// FullName returns the name of the command, or command|subcommand
+func (s *Spec) FullName() string {
+	if s.parent == nil {
+		return s.Name
+	}
+	return s.parent.Name + "|" + s.Name
+}
+
+// depth returns the number of arguments naming the command
+func (s *Spec) depth() int {
+	if s.parent == nil {
+		return 1
+	}
+	return 2
+}
+
+// Stats returns the counters of the command
+func (s *Spec) Stats() *Stats {
+	return s.stats
+}
+
+func (s *Spec) Parent() *Spec {
+	return s.parent
+}
+
+func (s *Spec) Subcommand(name string) *Spec {
+	lock.RLock()
+	defer lock.RUnlock()
+	return s.subcommand(name)
+}
+
+func (s *Spec) subcommand(name string) *Spec {
+	for _, sub := range s.subcommands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Subcommands returns the specs of the subcommands sorted by name
+func (s *Spec) Subcommands() []*Spec {
+	lock.RLock()
+	defer lock.RUnlock()
+	subs := slices.Clone(s.subcommands)
+	slices.SortFunc(subs, func(a, b *Spec) int { return cmp.Compare(a.Name, b.Name) })
+	return subs
+}
+
+// HelpLines returns the HELP of a command with subcommands,
+// HELP itself being listed last
+func (s *Spec) HelpLines() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+	lines := []string{strings.ToUpper(s.Name) + " <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"}
+	lines = append(lines, s.Help...)
+	for _, sub := range s.subcommands {
+		if sub.Name != "help" {
+			lines = append(lines, sub.Help...)
+		}
+	}
+	if help := s.subcommand("help"); help != nil {
+		lines = append(lines, help.Help...)
+	}
+	return lines
+}
+
+func (s *Spec) ValidateArity(args [][]byte) bool {
+	return (s.Arity > 0 && len(args) == s.Arity) ||
+		(s.Arity < 0 && len(args) >= -s.Arity)
+}
+
+func bitNames(bits int, names []string) []string {
+	result := make([]string, 0)
+	for i, name := range names {
+		if bits&(1<<i) != 0 {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func (s *Spec) FlagNames() []string {
+	return bitNames(s.Flags, flagNames)
+}
+
+// CategoryNames returns the ACL categories of the command, without the @ prefix
+func (s *Spec) CategoryNames() []string {
+	return bitNames(s.Categories, categoryNames)
+}
+
+func (k KeySpec) FlagNames() []string {
+	return bitNames(k.Flags, keyFlagNames)
+}
+
+func (k KeySpec) step() int {
+	return max(k.Step, 1)
+}
+
+// KeyRange returns the first key, last key and step of the legacy key
+// range, which is made of the first key spec
+func (s *Spec) KeyRange() (first, last, step int) {
+	if len(s.Keys) == 0 {
+		return 0, 0, 0
+	}
+	k := s.Keys[0]
+	if k.LastKey < 0 {
+		return k.Index, k.LastKey, k.step()
+	}
+	return k.Index, k.Index + k.LastKey, k.step()
+}
+
+// KeyPositions returns the indexes of the keys in the arguments
+func (s *Spec) KeyPositions(args [][]byte) []int {
+	positions := make([]int, 0)
+	for _, k := range s.Keys {
+		last := k.Index + k.LastKey
+		if k.LastKey < 0 {
+			last = len(args) + k.LastKey
+		}
+		for i := k.Index; i <= last && i < len(args); i += k.step() {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
@@ -0,0 +1,140 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/HwHgoo/Gredis/core/interface/redis"
+	"github.com/HwHgoo/Gredis/core/protocol"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func args(strs ...string) [][]byte {
+	result := make([][]byte, len(strs))
+	for i, s := range strs {
+		result[i] = []byte(s)
+	}
+	return result
+}
+
+func TestSpec(t *testing.T) {
+	Convey("TestSpec", t, func() {
+		Convey("key positions", func() {
+			single := &Spec{Name: "get", Arity: 2, Keys: []KeySpec{{Index: 1}}}
+			So(single.KeyPositions(args("get", "a")), ShouldResemble, []int{1})
+			first, last, step := single.KeyRange()
+			So([]int{first, last, step}, ShouldResemble, []int{1, 1, 1})
+
+			pairs := &Spec{Name: "mset", Arity: -3, Keys: []KeySpec{{Index: 1, LastKey: -1, Step: 2}}}
+			So(pairs.KeyPositions(args("mset", "a", "1", "b", "2")), ShouldResemble, []int{1, 3})
+			first, last, step = pairs.KeyRange()
+			So([]int{first, last, step}, ShouldResemble, []int{1, -1, 2})
+
+			two := &Spec{Name: "lcs", Arity: -3, Keys: []KeySpec{{Index: 1, LastKey: 1}}}
+			So(two.KeyPositions(args("lcs", "a", "b", "len")), ShouldResemble, []int{1, 2})
+
+			none := &Spec{Name: "ping", Arity: -1}
+			So(none.KeyPositions(args("ping")), ShouldBeEmpty)
+			first, last, step = none.KeyRange()
+			So([]int{first, last, step}, ShouldResemble, []int{0, 0, 0})
+		})
+
+		Convey("flags and categories", func() {
+			Register(Spec{Name: "spec-test", Arity: 2, Flags: FlagWrite | FlagFast, Categories: CategoryString},
+				DatabaseCommandExecutor(nil))
+			defer func() {
+				delete(dbCommands, "spec-test")
+				delete(specs, "spec-test")
+			}()
+
+			spec := Lookup("spec-test")
+			So(spec.FlagNames(), ShouldResemble, []string{"write", "fast"})
+			So(spec.CategoryNames(), ShouldResemble, []string{"write", "string", "fast"})
+			So(HasFlags("spec-test", FlagWrite), ShouldBeTrue)
+			So(HasFlags("spec-test", FlagReadOnly), ShouldBeFalse)
+			So(spec.ValidateArity(args("spec-test")), ShouldBeFalse)
+
+			admin := Spec{Flags: FlagAdmin}
+			So(bitNames(implicitCategories(admin.Flags), categoryNames), ShouldResemble, []string{"admin", "slow", "dangerous"})
+		})
+	})
+}
+
+func TestSubcommands(t *testing.T) {
+	Convey("TestSubcommands", t, func() {
+		Register(Spec{Name: "container", Arity: -2}, DatabaseCommandExecutor(nil))
+		RegisterSubcommand("container", Spec{Name: "sub", Arity: 3, Flags: FlagReadOnly, Help: []string{"SUB <arg>", "    A subcommand."}},
+			DatabaseCommandExecutor(func(db redis.DB, args [][]byte) protocol.RedisMessage { return protocol.MakeBulkString(args[0]) }))
+		defer func() {
+			delete(specs, "container")
+			for _, name := range []string{"container", "container|sub", "container|help"} {
+				delete(dbCommands, name)
+			}
+		}()
+
+		Convey("find", func() {
+			spec, errReply := Find(args("CONTAINER", "Sub", "x"))
+			So(errReply, ShouldBeNil)
+			So(spec.FullName(), ShouldEqual, "container|sub")
+			So(spec.Parent(), ShouldEqual, Lookup("container"))
+			So(ExecDatabaseCommand(spec, nil, args("container", "sub", "x")).Bytes(), ShouldResemble, []byte("$1\r\nx\r\n"))
+
+			_, errReply = Find(args("container", "foo"))
+			So(string(errReply.Bytes()), ShouldEqual, "-ERR unknown subcommand 'foo'. Try CONTAINER HELP.\r\n")
+			_, errReply = Find(args("container", "sub"))
+			So(string(errReply.Bytes()), ShouldEqual, "-ERR wrong number of arguments for 'container|sub' command\r\n")
+			_, errReply = Find(args("container"))
+			So(string(errReply.Bytes()), ShouldEqual, "-ERR wrong number of arguments for 'container' command\r\n")
+		})
+
+		Convey("help", func() {
+			spec, errReply := Find(args("container", "help"))
+			So(errReply, ShouldBeNil)
+			So(Lookup("container").Subcommands(), ShouldHaveLength, 2)
+			So(string(ExecDatabaseCommand(spec, nil, args("container", "help")).Bytes()), ShouldEqual, "*5\r\n"+
+				"+CONTAINER <subcommand> [<arg> [value] [opt] ...]. Subcommands are:\r\n"+
+				"+SUB <arg>\r\n+    A subcommand.\r\n+HELP\r\n+    Print this help.\r\n")
+		})
+	})
+}
+
+func TestRename(t *testing.T) {
+	Convey("TestRename", t, func() {
+		Register(Spec{Name: "container", Arity: -2}, DatabaseCommandExecutor(nil))
+		RegisterSubcommand("container", Spec{Name: "sub", Arity: 3},
+			DatabaseCommandExecutor(func(db redis.DB, args [][]byte) protocol.RedisMessage { return protocol.MakeBulkString(args[0]) }))
+		defer func() {
+			for _, name := range []string{"container", "renamed"} {
+				delete(specs, name)
+				for _, sub := range []string{"", "|sub", "|help"} {
+					delete(dbCommands, name+sub)
+				}
+			}
+		}()
+
+		Convey("rename", func() {
+			So(Rename("CONTAINER", "Renamed"), ShouldBeNil)
+			_, errReply := Find(args("container", "sub", "x"))
+			So(string(errReply.Bytes()), ShouldEqual, "-ERR unknown command `container`, with args beginning with: `s`\r\n")
+
+			spec, errReply := Find(args("renamed", "sub", "x"))
+			So(errReply, ShouldBeNil)
+			So(spec.FullName(), ShouldEqual, "renamed|sub")
+			So(ExecDatabaseCommand(spec, nil, args("renamed", "sub", "x")).Bytes(), ShouldResemble, []byte("$1\r\nx\r\n"))
+			So(Lookup("renamed").HelpLines()[0], ShouldStartWith, "RENAMED <subcommand>")
+		})
+
+		Convey("disable", func() {
+			So(Rename("container", ""), ShouldBeNil)
+			So(Exists("container"), ShouldBeFalse)
+			So(IsDbCommand("container|sub"), ShouldBeFalse)
+		})
+
+		Convey("unknown or existing names", func() {
+			So(Rename("no-such-command", "foo"), ShouldNotBeNil)
+			Register(Spec{Name: "renamed", Arity: 1}, DatabaseCommandExecutor(nil))
+			So(Rename("container", "renamed"), ShouldNotBeNil)
+			So(Exists("container"), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// distinct error prefixes tracked, errors with other prefixes are
+// only counted in the total once the limit is reached
+const max_error_prefixes = 128
+
+// Stats are the counters of a command reported by INFO commandstats
+type Stats struct {
+	Calls atomic.Int64
+	// total execution time in microseconds
+	Usec atomic.Int64
+	// calls refused before execution, e.g. for a wrong number of arguments
+	RejectedCalls atomic.Int64
+	// calls which replied with an error
+	FailedCalls atomic.Int64
+}
+
+func (st *Stats) reset() {
+	st.Calls.Store(0)
+	st.Usec.Store(0)
+	st.RejectedCalls.Store(0)
+	st.FailedCalls.Store(0)
+}
+
+// errorStats counts error replies by prefix, e.g. ERR or WRONGTYPE
+var errorStats = struct {
+	sync.Mutex
+	counts map[string]int64
+	total  int64
+}{counts: make(map[string]int64)}
+
+// CountError records an error reply under its prefix
+func CountError(reply protocol.RedisErrorMessage) {
+	prefix, _, _ := strings.Cut(reply.Error(), " ")
+
+	errorStats.Lock()
+	defer errorStats.Unlock()
+	errorStats.total++
+	if _, ok := errorStats.counts[prefix]; ok || len(errorStats.counts) < max_error_prefixes {
+		errorStats.counts[prefix]++
+	}
+}
+
+// ErrorStats returns the number of error replies by prefix
+func ErrorStats() map[string]int64 {
+	errorStats.Lock()
+	defer errorStats.Unlock()
+	counts := make(map[string]int64, len(errorStats.counts))
+	for prefix, count := range errorStats.counts {
+		counts[prefix] = count
+	}
+	return counts
+}
+
+// ErrorCount returns the total number of error replies
+func ErrorCount() int64 {
+	errorStats.Lock()
+	defer errorStats.Unlock()
+	return errorStats.total
+}
+
+// ResetStats resets the counters of every command and the error counters
+func ResetStats() {
+	lock.RLock()
+	for _, spec := range specs {
+		spec.stats.reset()
+		for _, sub := range spec.subcommands {
+			sub.stats.reset()
+		}
+	}
+	lock.RUnlock()
+
+	errorStats.Lock()
+	defer errorStats.Unlock()
+	errorStats.counts = make(map[string]int64)
+	errorStats.total = 0
+}
@@ -0,0 +1,32 @@
+package command
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/HwHgoo/Gredis/core/protocol"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrorStats(t *testing.T) {
+	Convey("TestErrorStats", t, func() {
+		ResetStats()
+		defer ResetStats()
+
+		CountError(&protocol.WrongTypeError)
+		CountError(protocol.MakeGenericError("foo"))
+		CountError(protocol.MakeGenericError("bar"))
+		So(ErrorStats(), ShouldResemble, map[string]int64{"WRONGTYPE": 1, "ERR": 2})
+		So(ErrorCount(), ShouldEqual, 3)
+
+		Convey("prefixes are limited", func() {
+			for i := 0; i < max_error_prefixes; i++ {
+				CountError(protocol.MakeBulkError("E" + strconv.Itoa(i) + " error").(protocol.RedisErrorMessage))
+			}
+			So(ErrorStats(), ShouldHaveLength, max_error_prefixes)
+			So(ErrorStats()["ERR"], ShouldEqual, 2)
+			So(ErrorCount(), ShouldEqual, 3+max_error_prefixes)
+		})
+	})
+}
@@ -1,7 +1,8 @@
 package db
 
 import (
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/HwHgoo/Gredis/core/command"
@@ -15,32 +16,98 @@ import (
 type Database struct {
 	index int
 
-	data    *datastructure.ConcurrentMap[any]
+	data    *datastructure.ConcurrentMap[*entry]
 	expires *datastructure.ConcurrentMap[time.Time]
+
+	// set on the view of a CLIENT NO-TOUCH connection,
+	// its reads don't update access times
+	noTouch bool
+	// that view, sharing the keys and lock of the database
+	noTouchView *Database
+
+	// held for reading by the commands of the database, and for
+	// writing by those reaching another database, e.g. MOVE
+	lock *sync.RWMutex
 }
 
 // TODO optimize for operation like mget, mset
 func MakeDatabase() *Database {
-	return &Database{
-		data:    datastructure.MakeNewConcurrentMap[any](),
+	db := &Database{
+		data:    datastructure.MakeNewConcurrentMap[*entry](),
 		expires: datastructure.MakeNewConcurrentMap[time.Time](),
+		lock:    &sync.RWMutex{},
 	}
+	view := *db
+	view.noTouch = true
+	db.noTouchView = &view
+	return db
 }
 
+// specs of the commands locking the database for writing themselves,
+// kept so that they are still recognized once renamed
+var renameSpec, renamenxSpec *command.Spec
+
 func (db *Database) Exec(conn redis.Connection, args [][]byte) protocol.RedisMessage {
-	return db.execNormal(args)
+	spec, errReply := command.Find(args)
+	if errReply != nil {
+		return errReply
+	}
+	if spec != renameSpec && spec != renamenxSpec {
+		db.lock.RLock()
+		defer db.lock.RUnlock()
+	}
+	if conn.NoTouch() {
+		db = db.noTouchView
+	}
+	return command.ExecDatabaseCommand(spec, db, args)
+}
+
+// a value along the last time, in unix nanoseconds, it was read or written
+type entry struct {
+	value  any
+	access atomic.Int64
+}
+
+func makeEntry(value any) *entry {
+	e := &entry{value: value}
+	e.touch()
+	return e
+}
+
+func (e *entry) touch() {
+	e.access.Store(time.Now().UnixNano())
 }
 
 func (db *Database) Get(key string) (value any, ok bool) {
-	if db.IsExpired(key) {
+	e, ok := db.lookup(key)
+	if !ok {
 		return nil, false
 	}
+	if !db.noTouch {
+		e.touch()
+	}
+	return e.value, true
+}
 
+// lookup returns the entry of key without updating its access time
+func (db *Database) lookup(key string) (*entry, bool) {
+	if db.IsExpired(key) {
+		return nil, false
+	}
 	return db.data.Get(key)
 }
 
 func (db *Database) Set(key string, value any) {
-	db.data.Set(key, value)
+	db.data.Set(key, makeEntry(value))
+}
+
+// IdleTime returns how long ago the key was last read or written
+func (db *Database) IdleTime(key string) (time.Duration, bool) {
+	e, ok := db.data.Get(key)
+	if !ok {
+		return 0, false
+	}
+	return time.Since(time.Unix(0, e.access.Load())), true
 }
 
 func (db *Database) SetIfAbsent(key string, value any) int {
@@ -49,7 +116,7 @@ func (db *Database) SetIfAbsent(key string, value any) int {
 		return 0
 	}
 
-	db.data.Set(key, value)
+	db.Set(key, value)
 	return 1
 }
 
@@ -58,17 +125,21 @@ func (db *Database) SetIfExist(key string, value any) int {
 	if !ok {
 		return 0
 	}
-	db.data.Set(key, value)
+	db.Set(key, value)
 	return 1
 }
 
 func (db *Database) Delete(key string) int {
+	if db.IsExpired(key) {
+		return 0
+	}
 	_, ok := db.data.Get(key)
 	if !ok {
 		return 0
 	}
 
 	db.data.Delete(key)
+	db.expires.Delete(key)
 	return 1
 }
 
@@ -80,9 +151,9 @@ func (db *Database) Persist(key string) {
 	db.expires.Delete(key)
 }
 
-func (db *Database) execNormal(args [][]byte) protocol.RedisMessage {
-	cmdName := strings.ToLower(string(args[0]))
-	return command.ExecDatabaseCommand(cmdName, db, args[1:])
+// ExpireAt returns the time key expires at, if it has one
+func (db *Database) ExpireAt(key string) (time.Time, bool) {
+	return db.expires.Get(key)
 }
 
 // check if key is expired
@@ -96,6 +167,7 @@ func (db *Database) IsExpired(key string) bool {
 	expired := time.Now().After(t)
 	if expired {
 		db.data.Delete(key)
+		db.expires.Delete(key)
 	}
 
 	return expired
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeConnection struct {
+	noTouch bool
+}
+
+func (c *fakeConnection) GetSelectedDb() int { return 0 }
+
+func (c *fakeConnection) SelectDb(int) {}
+
+func (c *fakeConnection) NoTouch() bool { return c.noTouch }
+
+func TestAccessTime(t *testing.T) {
+	Convey("TestAccessTime", t, func() {
+		db := MakeDatabase()
+		db.Set("key", []byte("value"))
+		time.Sleep(20 * time.Millisecond)
+
+		Convey("reads through a no-touch connection keep the access time", func() {
+			db.Exec(&fakeConnection{noTouch: true}, [][]byte{[]byte("get"), []byte("key")})
+			idle, ok := db.IdleTime("key")
+			So(ok, ShouldBeTrue)
+			So(idle, ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+		})
+
+		Convey("other reads update it", func() {
+			db.Exec(&fakeConnection{}, [][]byte{[]byte("get"), []byte("key")})
+			idle, ok := db.IdleTime("key")
+			So(ok, ShouldBeTrue)
+			So(idle, ShouldBeLessThan, 20*time.Millisecond)
+		})
+
+		Convey("deleted keys have no access time", func() {
+			db.Delete("key")
+			_, ok := db.IdleTime("key")
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/datastructure/zset"
+	"github.com/HwHgoo/Gredis/module"
+)
+
+var copyNotSupportedError = protocol.MakeGenericError("not supported for this module key")
+
+// copyValue returns a deep copy of value
+func copyValue(value any) (any, protocol.RedisErrorMessage) {
+	switch v := value.(type) {
+	case []byte:
+		return bytes.Clone(v), nil
+	case zset.ZSet:
+		return v.Copy(), nil
+	case *module.Value:
+		if v.Type.Copy == nil {
+			return nil, copyNotSupportedError
+		}
+		return &module.Value{Type: v.Type, Data: v.Type.Copy(v.Data)}, nil
+	}
+	return value, nil
+}
+
+// setWithExpire sets key, expiring at expireAt unless it is zero
+func (db *Database) setWithExpire(key string, value any, expireAt time.Time) {
+	db.Set(key, value)
+	if expireAt.IsZero() {
+		db.Persist(key)
+	} else {
+		db.Expire(key, expireAt)
+	}
+}
+
+// held while locking two databases, so that no two pairs are
+// locked in opposite orders
+var pairLock sync.Mutex
+
+// lockPair locks db and dst for writing, which may be the same database
+func (db *Database) lockPair(dst *Database) (unlock func()) {
+	if db.lock == dst.lock {
+		db.lock.Lock()
+		return db.lock.Unlock
+	}
+	pairLock.Lock()
+	db.lock.Lock()
+	dst.lock.Lock()
+	pairLock.Unlock()
+	return func() {
+		dst.lock.Unlock()
+		db.lock.Unlock()
+	}
+}
+
+// CopyTo copies key and its expire time to dstKey in dst, which is only
+// overwritten if replace is set. The access time of key is updated if
+// touch is set. It returns 1 if key was copied.
+func (db *Database) CopyTo(key string, dst *Database, dstKey string, replace, touch bool) (int, protocol.RedisErrorMessage) {
+	defer db.lockPair(dst)()
+	e, ok := db.lookup(key)
+	if !ok {
+		return 0, nil
+	}
+	if touch {
+		e.touch()
+	}
+	if _, exists := dst.peek(dstKey); exists && !replace {
+		return 0, nil
+	}
+
+	value, err := copyValue(e.value)
+	if err != nil {
+		return 0, err
+	}
+	expireAt, _ := db.ExpireAt(key)
+	dst.setWithExpire(dstKey, value, expireAt)
+	return 1, nil
+}
+
+// MoveTo moves key and its expire time to dst, unless the key
+// exists there. It returns 1 if key was moved.
+func (db *Database) MoveTo(key string, dst *Database) int {
+	defer db.lockPair(dst)()
+	value, ok := db.peek(key)
+	if !ok {
+		return 0
+	}
+	if _, exists := dst.peek(key); exists {
+		return 0
+	}
+
+	expireAt, _ := db.ExpireAt(key)
+	db.Delete(key)
+	dst.setWithExpire(key, value, expireAt)
+	return 1
+}
+
+// rename moves key to newKey along its expire time. With nx
+// newKey isn't overwritten. It returns 1 if key was renamed.
+func (db *Database) rename(key, newKey string, nx bool) (int, protocol.RedisErrorMessage) {
+	defer db.lockPair(db)()
+	value, ok := db.Get(key)
+	if !ok {
+		return 0, &protocol.NoSuchKeyError
+	}
+	if key == newKey {
+		if nx {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	if _, exists := db.peek(newKey); exists && nx {
+		return 0, nil
+	}
+
+	expireAt, _ := db.ExpireAt(key)
+	// the key may have expired since it was read
+	if db.Delete(key) == 0 {
+		return 0, &protocol.NoSuchKeyError
+	}
+	db.setWithExpire(newKey, value, expireAt)
+	return 1, nil
+}
+
+// EXISTS key [key ...], a key given several times is counted as many times
+func existsCommand(db *Database, args CommandParams) protocol.RedisMessage {
+	count := 0
+	for _, arg := range args {
+		if _, ok := db.peek(string(arg)); ok {
+			count++
+		}
+	}
+	return protocol.MakeInteger(int64(count))
+}
+
+// TYPE key
+func typeCommand(db *Database, args CommandParams) protocol.RedisMessage {
+	value, ok := db.peek(string(args[0]))
+	if !ok {
+		return protocol.MakeSimpleString([]byte("none"))
+	}
+	name, _ := typeOf(value)
+	return protocol.MakeSimpleString([]byte(name))
+}
+
+// RENAME key newkey
+func renameCommand(db *Database, args CommandParams) protocol.RedisMessage {
+	if _, err := db.rename(string(args[0]), string(args[1]), false); err != nil {
+		return err
+	}
+	return &protocol.RedisOk
+}
+
+// RENAMENX key newkey
+func renamenxCommand(db *Database, args CommandParams) protocol.RedisMessage {
+	renamed, err := db.rename(string(args[0]), string(args[1]), true)
+	if err != nil {
+		return err
+	}
+	return protocol.MakeInteger(int64(renamed))
+}
+
+// TOUCH key [key ...], access times are updated even for NO-TOUCH clients
+func touchCommand(db *Database, args CommandParams) protocol.RedisMessage {
+	count := 0
+	for _, arg := range args {
+		key := string(arg)
+		if e, ok := db.lookup(key); ok {
+			e.touch()
+			count++
+		}
+	}
+	return protocol.MakeInteger(int64(count))
+}
+
+func registerGenericCommands() {
+	register(command.Spec{
+		Name: "exists", Arity: -2, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategoryKeyspace,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO, Index: 1, LastKey: -1}},
+		Summary: "Determines whether one or more keys exist.",
+		Since:   "1.0.0", Group: "generic", Complexity: "O(N) where N is the number of keys to check.",
+	}, existsCommand)
+	register(command.Spec{
+		Name: "type", Arity: 2, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategoryKeyspace,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO, Index: 1}},
+		Summary: "Determines the type of value stored at a key.",
+		Since:   "1.0.0", Group: "generic", Complexity: "O(1)",
+	}, typeCommand)
+	register(command.Spec{
+		Name: "rename", Arity: 3, Flags: command.FlagWrite, Categories: command.CategoryKeyspace,
+		Keys: []command.KeySpec{
+			{Flags: command.KeyRW | command.KeyAccess | command.KeyDelete, Index: 1},
+			{Flags: command.KeyOW | command.KeyUpdate, Index: 2},
+		},
+		Summary: "Renames a key and overwrites the destination.",
+		Since:   "1.0.0", Group: "generic", Complexity: "O(1)",
+	}, renameCommand)
+	register(command.Spec{
+		Name: "renamenx", Arity: 3, Flags: command.FlagWrite | command.FlagFast, Categories: command.CategoryKeyspace,
+		Keys: []command.KeySpec{
+			{Flags: command.KeyRW | command.KeyAccess | command.KeyDelete, Index: 1},
+			{Flags: command.KeyOW | command.KeyInsert, Index: 2},
+		},
+		Summary: "Renames a key only when the target key name doesn't exist.",
+		Since:   "1.0.0", Group: "generic", Complexity: "O(1)",
+	}, renamenxCommand)
+	register(command.Spec{
+		Name: "touch", Arity: -2, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategoryKeyspace,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO, Index: 1, LastKey: -1}},
+		Summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.",
+		Since:   "3.2.1", Group: "generic", Complexity: "O(N) where N is the number of keys that will be touched.",
+	}, touchCommand)
+	renameSpec, renamenxSpec = command.Lookup("rename"), command.Lookup("renamenx")
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/datastructure/zset"
+	"github.com/HwHgoo/Gredis/module"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func exec(db *Database, args ...string) string {
+	argv := make([][]byte, len(args))
+	for i, arg := range args {
+		argv[i] = []byte(arg)
+	}
+	return string(db.Exec(&fakeConnection{}, argv).Bytes())
+}
+
+func TestGenericCommands(t *testing.T) {
+	Convey("TestGenericCommands", t, func() {
+		db := MakeDatabase()
+		db.Set("str", []byte("v"))
+		db.Set("expired", []byte("v"))
+		db.Expire("expired", time.Now().Add(-time.Second))
+		zs := zset.NewZSet()
+		zs.Insert("m", 1)
+		db.Set("zs", zs)
+
+		Convey("exists and type", func() {
+			So(exec(db, "exists", "str", "zs", "str", "missing", "expired"), ShouldEqual, ":3\r\n")
+			So(exec(db, "type", "str"), ShouldEqual, "+string\r\n")
+			So(exec(db, "type", "zs"), ShouldEqual, "+zset\r\n")
+			So(exec(db, "type", "expired"), ShouldEqual, "+none\r\n")
+		})
+
+		Convey("rename carries the expire time", func() {
+			expireAt := time.Now().Add(time.Hour)
+			db.Expire("str", expireAt)
+			db.Set("dst", []byte("old"))
+			db.Expire("dst", time.Now().Add(time.Minute))
+			So(exec(db, "rename", "str", "dst"), ShouldEqual, "+OK\r\n")
+			So(exec(db, "get", "dst"), ShouldEqual, "$1\r\nv\r\n")
+			at, ok := db.ExpireAt("dst")
+			So(ok, ShouldBeTrue)
+			So(at, ShouldEqual, expireAt)
+			_, ok = db.ExpireAt("str")
+			So(ok, ShouldBeFalse)
+
+			So(exec(db, "rename", "zs", "dst"), ShouldEqual, "+OK\r\n")
+			_, ok = db.ExpireAt("dst")
+			So(ok, ShouldBeFalse)
+			So(exec(db, "get", "dst"), ShouldStartWith, "-WRONGTYPE")
+		})
+
+		Convey("rename of missing or expired keys", func() {
+			So(exec(db, "rename", "missing", "dst"), ShouldEqual, "-ERR no such key\r\n")
+			So(exec(db, "rename", "expired", "dst"), ShouldEqual, "-ERR no such key\r\n")
+			So(exec(db, "rename", "str", "str"), ShouldEqual, "+OK\r\n")
+			So(exec(db, "renamenx", "str", "str"), ShouldEqual, ":0\r\n")
+		})
+
+		Convey("renamenx", func() {
+			So(exec(db, "renamenx", "str", "zs"), ShouldEqual, ":0\r\n")
+			So(exec(db, "renamenx", "str", "expired"), ShouldEqual, ":1\r\n")
+			So(exec(db, "get", "expired"), ShouldEqual, "$1\r\nv\r\n")
+			So(exec(db, "exists", "str"), ShouldEqual, ":0\r\n")
+		})
+
+		Convey("copies are deep", func() {
+			other := MakeDatabase()
+			copied, err := db.CopyTo("zs", other, "zs", false, true)
+			So(err, ShouldBeNil)
+			So(copied, ShouldEqual, 1)
+			So(exec(db, "zadd", "zs", "2", "n"), ShouldEqual, ":1\r\n")
+			So(exec(other, "zcard", "zs"), ShouldEqual, ":1\r\n")
+
+			copied, _ = db.CopyTo("zs", other, "zs", false, true)
+			So(copied, ShouldEqual, 0)
+			copied, _ = db.CopyTo("zs", other, "zs", true, true)
+			So(copied, ShouldEqual, 1)
+			So(exec(other, "zcard", "zs"), ShouldEqual, ":2\r\n")
+			copied, _ = db.CopyTo("expired", other, "x", false, true)
+			So(copied, ShouldEqual, 0)
+		})
+
+		Convey("copies without touch keep the access time", func() {
+			time.Sleep(20 * time.Millisecond)
+			copied, _ := db.CopyTo("str", db, "copy", false, false)
+			So(copied, ShouldEqual, 1)
+			idle, _ := db.IdleTime("str")
+			So(idle, ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+		})
+
+		Convey("a move waits for a copy to the same key", func() {
+			copying := make(chan struct{})
+			slow := &module.Type{Name: "slow-copy", Copy: func(data any) any {
+				close(copying)
+				time.Sleep(10 * time.Millisecond)
+				return data
+			}}
+			db.Set("slow", &module.Value{Type: slow, Data: "copied"})
+			other := MakeDatabase()
+
+			var copied int
+			done := make(chan struct{})
+			go func() {
+				copied, _ = db.CopyTo("slow", other, "str", false, true)
+				close(done)
+			}()
+			<-copying
+			moved := db.MoveTo("str", other)
+			<-done
+			So(copied+moved, ShouldEqual, 1)
+			value, _ := other.Get("str")
+			So(value, ShouldHaveSameTypeAs, &module.Value{})
+		})
+
+		Convey("renames of the same key wait for the commands in flight", func() {
+			// a command in flight
+			db.lock.RLock()
+			replies := make(chan string, 2)
+			for _, newKey := range []string{"b", "c"} {
+				go func() { replies <- exec(db, "rename", "str", newKey) }()
+			}
+			time.Sleep(20 * time.Millisecond)
+			So(replies, ShouldBeEmpty)
+			db.lock.RUnlock()
+
+			first, second := <-replies, <-replies
+			So([]string{first, second}, ShouldContain, "+OK\r\n")
+			So([]string{first, second}, ShouldContain, "-ERR no such key\r\n")
+			So(exec(db, "exists", "str", "b", "c"), ShouldEqual, ":1\r\n")
+		})
+
+		Convey("touch", func() {
+			time.Sleep(20 * time.Millisecond)
+			argv := [][]byte{[]byte("touch"), []byte("str"), []byte("missing"), []byte("expired")}
+			So(db.Exec(&fakeConnection{noTouch: true}, argv), ShouldResemble, protocol.MakeInteger(1))
+			idle, _ := db.IdleTime("str")
+			So(idle, ShouldBeLessThan, 20*time.Millisecond)
+		})
+
+		Convey("deleting an expired key", func() {
+			So(exec(db, "del", "expired"), ShouldEqual, ":0\r\n")
+			db.Set("expired", []byte("v"))
+			_, ok := db.ExpireAt("expired")
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/datastructure/zset"
+	"github.com/HwHgoo/Gredis/module"
+)
+
+const (
+	// strings up to this length are embedded with their object in Redis
+	embstr_size_limit = 44
+	// estimated size of a key with the bookkeeping of its value
+	key_overhead = 64
+	// estimated size of a zset member, besides its name, in the skiplist and the map
+	zset_member_overhead = 80
+	// zset members sampled by default by MEMORY USAGE
+	memory_usage_samples = 5
+)
+
+var lfuNotSelectedError = protocol.MakeGenericError("An LFU maxmemory policy is not selected, access frequency not tracked. " +
+	"Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+
+// typeOf returns the name of the type of value reported by TYPE,
+// and its encoding reported by OBJECT ENCODING
+func typeOf(value any) (string, string) {
+	switch v := value.(type) {
+	case []byte:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil && strconv.FormatInt(n, 10) == string(v) {
+			return "string", "int"
+		}
+		if len(v) <= embstr_size_limit {
+			return "string", "embstr"
+		}
+		return "string", "raw"
+	case zset.ZSet:
+		return "zset", "skiplist"
+	case *module.Value:
+		return v.Type.Name, "raw"
+	}
+	return "none", ""
+}
+
+// peek returns the value of key without updating its access time
+func (db *Database) peek(key string) (any, bool) {
+	e, ok := db.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// OBJECT ENCODING key
+func objectEncoding(db *Database, args CommandParams) protocol.RedisMessage {
+	value, ok := db.peek(string(args[0]))
+	if !ok {
+		return protocol.MakeNil()
+	}
+	_, encoding := typeOf(value)
+	return protocol.MakeBulkString([]byte(encoding))
+}
+
+// OBJECT IDLETIME key
+func objectIdleTime(db *Database, args CommandParams) protocol.RedisMessage {
+	if _, ok := db.peek(string(args[0])); !ok {
+		return protocol.MakeNil()
+	}
+	idle, _ := db.IdleTime(string(args[0]))
+	return protocol.MakeInteger(int64(idle.Seconds()))
+}
+
+// OBJECT REFCOUNT key, values are never shared
+func objectRefCount(db *Database, args CommandParams) protocol.RedisMessage {
+	if _, ok := db.peek(string(args[0])); !ok {
+		return protocol.MakeNil()
+	}
+	return protocol.MakeInteger(1)
+}
+
+// OBJECT FREQ key, there is no LFU eviction
+func objectFreq(db *Database, args CommandParams) protocol.RedisMessage {
+	return lfuNotSelectedError
+}
+
+// MEMORY USAGE key [SAMPLES count]
+func memoryUsage(db *Database, args CommandParams) protocol.RedisMessage {
+	samples := memory_usage_samples
+	if len(args) > 1 {
+		if len(args) != 3 || !strings.EqualFold(string(args[1]), "samples") {
+			return &protocol.SyntaxError
+		}
+		n, err := strconv.Atoi(string(args[2]))
+		if err != nil || n < 0 {
+			return &protocol.InvalidIntegerError
+		}
+		samples = n
+	}
+
+	key := string(args[0])
+	value, ok := db.peek(key)
+	if !ok {
+		return protocol.MakeNil()
+	}
+	return protocol.MakeInteger(int64(len(key)) + key_overhead + valueSize(value, samples))
+}
+
+// valueSize estimates the size of a value in bytes. The size of
+// zset members is averaged over samples of them, 0 meaning all.
+func valueSize(value any, samples int) int64 {
+	switch v := value.(type) {
+	case []byte:
+		return int64(cap(v))
+	case zset.ZSet:
+		card := v.Card()
+		if samples == 0 || samples > card {
+			samples = card
+		}
+		all := &zset.ZRangeSpec{Min: math.Inf(-1), Max: math.Inf(1)}
+		sampled := 0
+		for i := 0; i < samples; i++ {
+			sampled += len(v.NthInRange(all, i).Name())
+		}
+		perMember := int64(zset_member_overhead)
+		if samples > 0 {
+			perMember += int64(sampled / samples)
+		}
+		return int64(card) * perMember
+	case *module.Value:
+		if v.Type.MemoryUsage != nil {
+			return v.Type.MemoryUsage(v.Data)
+		}
+	}
+	return 0
+}
+
+func registerObjectCommands() {
+	register(command.Spec{
+		Name: "object", Arity: -2,
+		Summary: "A container for object introspection commands.",
+		Since:   "2.2.3", Group: "generic", Complexity: "Depends on subcommand.",
+	}, nil)
+	keys := []command.KeySpec{{Flags: command.KeyRO, Index: 2}}
+	registerSubcommand("object", command.Spec{
+		Name: "encoding", Arity: 3, Flags: command.FlagReadOnly, Categories: command.CategoryKeyspace, Keys: keys,
+		Help: []string{
+			"ENCODING <key>",
+			"    Return the kind of internal representation used in order to store the value",
+			"    associated with a <key>.",
+		},
+		Summary: "Returns the internal encoding of a Redis object.",
+		Since:   "2.2.3", Group: "generic", Complexity: "O(1)",
+	}, objectEncoding)
+	registerSubcommand("object", command.Spec{
+		Name: "freq", Arity: 3, Flags: command.FlagReadOnly, Categories: command.CategoryKeyspace, Keys: keys,
+		Help: []string{
+			"FREQ <key>",
+			"    Return the access frequency index of the <key>. The returned integer is",
+			"    proportional to the logarithm of the recent access frequency of the key.",
+		},
+		Summary: "Returns the logarithmic access frequency counter of a Redis object.",
+		Since:   "4.0.0", Group: "generic", Complexity: "O(1)",
+	}, objectFreq)
+	registerSubcommand("object", command.Spec{
+		Name: "idletime", Arity: 3, Flags: command.FlagReadOnly, Categories: command.CategoryKeyspace, Keys: keys,
+		Help: []string{
+			"IDLETIME <key>",
+			"    Return the idle time of the <key>, that is the approximated number of",
+			"    seconds elapsed since the last access to the key.",
+		},
+		Summary: "Returns the time since the last access to a Redis object.",
+		Since:   "2.2.3", Group: "generic", Complexity: "O(1)",
+	}, objectIdleTime)
+	registerSubcommand("object", command.Spec{
+		Name: "refcount", Arity: 3, Flags: command.FlagReadOnly, Categories: command.CategoryKeyspace, Keys: keys,
+		Help: []string{
+			"REFCOUNT <key>",
+			"    Return the number of references of the value associated with the specified",
+			"    <key>.",
+		},
+		Summary: "Returns the reference count of a value of a key.",
+		Since:   "2.2.3", Group: "generic", Complexity: "O(1)",
+	}, objectRefCount)
+
+	register(command.Spec{
+		Name: "memory", Arity: -2,
+		Summary: "A container for memory diagnostics commands.",
+		Since:   "4.0.0", Group: "server", Complexity: "Depends on subcommand.",
+	}, nil)
+	registerSubcommand("memory", command.Spec{
+		Name: "usage", Arity: -3, Flags: command.FlagReadOnly, Keys: keys,
+		Help: []string{
+			"USAGE <key> [SAMPLES <count>]",
+			"    Return memory in bytes used by <key> and its value. Nested values are",
+			"    sampled up to <count> times (default: 5, 0 means sample all).",
+		},
+		Summary: "Estimates the memory usage of a key.",
+		Since:   "4.0.0", Group: "server", Complexity: "O(N) where N is the number of samples.",
+	}, memoryUsage)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/HwHgoo/Gredis/core/command"
 	"github.com/HwHgoo/Gredis/core/protocol"
 	"github.com/HwHgoo/Gredis/global"
 	"github.com/HwHgoo/Gredis/utils"
@@ -531,7 +532,7 @@ func lcsCommand(db *Database, args CommandParams) protocol.RedisMessage {
 		return msg
 	}
 
-	nomatch := protocol.MakeArray([]protocol.RedisMessage{
+	nomatch := protocol.MakeMap([]protocol.RedisMessage{
 		protocol.MakeBulkString([]byte("matches")),
 		protocol.MakeArray(nil),
 		protocol.MakeBulkString([]byte("len")),
@@ -629,7 +630,7 @@ func lcsCommand(db *Database, args CommandParams) protocol.RedisMessage {
 		matches = append(matches, makematch(0, last-1))
 	}
 
-	return protocol.MakeArray([]protocol.RedisMessage{
+	return protocol.MakeMap([]protocol.RedisMessage{
 		protocol.MakeBulkString([]byte("matches")),
 		protocol.MakeArray(matches),
 		protocol.MakeBulkString([]byte("len")),
@@ -649,21 +650,106 @@ func withFlags(flag int, flags ...int) bool {
 
 func registerStringCommands() {
 	// string commands
-	register("set", -3, setCommand)
-	register("mset", -3, msetCommand)
-	register("setrange", 4, setrangeCommand)
-	register("del", -2, delCommand)
-	register("get", 2, getCommand)
-	register("getdel", 2, getdelCommand)
-	register("getex", -2, getexCommand)
-	register("getrange", 4, getrangeCommand)
-	register("mget", -2, mgetCommand)
-	register("incr", 2, incrCommand)
-	register("incrby", 3, incrbyCommand)
-	register("decr", 2, decrCommand)
-	register("decrby", 3, decrbyCommand)
-	register("incrbyfloat", 3, incrbyfloatCommand)
-	register("append", 3, appendCommand)
-	register("lcs", -3, lcsCommand)
-	register("strlen", 2, strlenCommand)
+	register(command.Spec{
+		Name: "set", Arity: -3, Flags: command.FlagWrite | command.FlagDenyOOM, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyUpdate, Index: 1}},
+		Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.",
+		Since:   "1.0.0", Group: "string", Complexity: "O(1)",
+	}, setCommand)
+	register(command.Spec{
+		Name: "mset", Arity: -3, Flags: command.FlagWrite | command.FlagDenyOOM, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyOW | command.KeyUpdate, Index: 1, LastKey: -1, Step: 2}},
+		Summary: "Atomically creates or modifies the string values of one or more keys.",
+		Since:   "1.0.1", Group: "string", Complexity: "O(N) where N is the number of keys to set.",
+	}, msetCommand)
+	register(command.Spec{
+		Name: "setrange", Arity: 4, Flags: command.FlagWrite | command.FlagDenyOOM, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyUpdate, Index: 1}},
+		Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.",
+		Since:   "2.2.0", Group: "string", Complexity: "O(1), not counting the time taken to copy the new string in place.",
+	}, setrangeCommand)
+	register(command.Spec{
+		Name: "del", Arity: -2, Flags: command.FlagWrite, Categories: command.CategoryKeyspace,
+		Keys:    []command.KeySpec{{Flags: command.KeyRM | command.KeyDelete, Index: 1, LastKey: -1}},
+		Summary: "Deletes one or more keys.",
+		Since:   "1.0.0", Group: "generic", Complexity: "O(N) where N is the number of keys that will be removed.",
+	}, delCommand)
+	register(command.Spec{
+		Name: "get", Arity: 2, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO | command.KeyAccess, Index: 1}},
+		Summary: "Returns the string value of a key.",
+		Since:   "1.0.0", Group: "string", Complexity: "O(1)",
+	}, getCommand)
+	register(command.Spec{
+		Name: "getdel", Arity: 2, Flags: command.FlagWrite | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyDelete, Index: 1}},
+		Summary: "Returns the string value of a key after deleting the key.",
+		Since:   "6.2.0", Group: "string", Complexity: "O(1)",
+	}, getdelCommand)
+	register(command.Spec{
+		Name: "getex", Arity: -2, Flags: command.FlagWrite | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyUpdate, Index: 1}},
+		Summary: "Returns the string value of a key after setting its expiration time.",
+		Since:   "6.2.0", Group: "string", Complexity: "O(1)",
+	}, getexCommand)
+	register(command.Spec{
+		Name: "getrange", Arity: 4, Flags: command.FlagReadOnly, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO | command.KeyAccess, Index: 1}},
+		Summary: "Returns a substring of the string stored at a key.",
+		Since:   "2.4.0", Group: "string", Complexity: "O(N) where N is the length of the returned string.",
+	}, getrangeCommand)
+	register(command.Spec{
+		Name: "mget", Arity: -2, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO | command.KeyAccess, Index: 1, LastKey: -1}},
+		Summary: "Atomically returns the string values of one or more keys.",
+		Since:   "1.0.0", Group: "string", Complexity: "O(N) where N is the number of keys to retrieve.",
+	}, mgetCommand)
+	register(command.Spec{
+		Name: "incr", Arity: 2, Flags: command.FlagWrite | command.FlagDenyOOM | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyUpdate, Index: 1}},
+		Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+		Since:   "1.0.0", Group: "string", Complexity: "O(1)",
+	}, incrCommand)
+	register(command.Spec{
+		Name: "incrby", Arity: 3, Flags: command.FlagWrite | command.FlagDenyOOM | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyUpdate, Index: 1}},
+		Summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+		Since:   "1.0.0", Group: "string", Complexity: "O(1)",
+	}, incrbyCommand)
+	register(command.Spec{
+		Name: "decr", Arity: 2, Flags: command.FlagWrite | command.FlagDenyOOM | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyUpdate, Index: 1}},
+		Summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+		Since:   "1.0.0", Group: "string", Complexity: "O(1)",
+	}, decrCommand)
+	register(command.Spec{
+		Name: "decrby", Arity: 3, Flags: command.FlagWrite | command.FlagDenyOOM | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyUpdate, Index: 1}},
+		Summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.",
+		Since:   "1.0.0", Group: "string", Complexity: "O(1)",
+	}, decrbyCommand)
+	register(command.Spec{
+		Name: "incrbyfloat", Arity: 3, Flags: command.FlagWrite | command.FlagDenyOOM | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyUpdate, Index: 1}},
+		Summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+		Since:   "2.6.0", Group: "string", Complexity: "O(1)",
+	}, incrbyfloatCommand)
+	register(command.Spec{
+		Name: "append", Arity: 3, Flags: command.FlagWrite | command.FlagDenyOOM | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyInsert, Index: 1}},
+		Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.",
+		Since:   "2.0.0", Group: "string", Complexity: "O(1)",
+	}, appendCommand)
+	register(command.Spec{
+		Name: "lcs", Arity: -3, Flags: command.FlagReadOnly, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO | command.KeyAccess, Index: 1, LastKey: 1}},
+		Summary: "Finds the longest common substring.",
+		Since:   "7.0.0", Group: "string", Complexity: "O(N*M) where N and M are the lengths of s1 and s2, respectively",
+	}, lcsCommand)
+	register(command.Spec{
+		Name: "strlen", Arity: 2, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategoryString,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO, Index: 1}},
+		Summary: "Returns the length of a string value.",
+		Since:   "2.2.0", Group: "string", Complexity: "O(1)",
+	}, strlenCommand)
 }
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/HwHgoo/Gredis/core/command"
 	"github.com/HwHgoo/Gredis/core/protocol"
 	"github.com/HwHgoo/Gredis/datastructure/zset"
 	"github.com/HwHgoo/Gredis/utils"
@@ -178,7 +179,7 @@ func (db *Database) zaddGenericCommand(args CommandParams, flags int) protocol.R
 
 	if incr {
 		if processed > 0 {
-			return protocol.MakeBulkString(utils.FloatBytes(newscore))
+			return protocol.MakeDouble(newscore)
 		} else {
 			return &protocol.RedisNil
 		}
@@ -252,7 +253,7 @@ func zscoreCommand(db *Database, args CommandParams) protocol.RedisMessage {
 		return &protocol.RedisNil
 	}
 
-	return protocol.MakeBulkString(utils.FloatBytes(score))
+	return protocol.MakeDouble(score)
 }
 
 func makeZRange(min, max string) (*zset.ZRangeSpec, protocol.RedisErrorMessage) {
@@ -281,8 +282,28 @@ func makeZRange(min, max string) (*zset.ZRangeSpec, protocol.RedisErrorMessage)
 
 func registerZSetCommands() {
 	// zset commands
-	register("zadd", -4, zaddCommand)
-	register("zcard", 2, zcardCommand)
-	register("zcount", 4, zcountCommand)
-	register("zscore", 3, zscoreCommand)
+	register(command.Spec{
+		Name: "zadd", Arity: -4, Flags: command.FlagWrite | command.FlagDenyOOM | command.FlagFast, Categories: command.CategorySortedSet,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyUpdate, Index: 1}},
+		Summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.",
+		Since:   "1.2.0", Group: "sorted-set", Complexity: "O(log(N)) for each item added, where N is the number of elements in the sorted set.",
+	}, zaddCommand)
+	register(command.Spec{
+		Name: "zcard", Arity: 2, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategorySortedSet,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO, Index: 1}},
+		Summary: "Returns the number of members in a sorted set.",
+		Since:   "1.2.0", Group: "sorted-set", Complexity: "O(1)",
+	}, zcardCommand)
+	register(command.Spec{
+		Name: "zcount", Arity: 4, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategorySortedSet,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO | command.KeyAccess, Index: 1}},
+		Summary: "Returns the count of members in a sorted set that have scores within a range.",
+		Since:   "2.0.0", Group: "sorted-set", Complexity: "O(log(N)) with N being the number of elements in the sorted set.",
+	}, zcountCommand)
+	register(command.Spec{
+		Name: "zscore", Arity: 3, Flags: command.FlagReadOnly | command.FlagFast, Categories: command.CategorySortedSet,
+		Keys:    []command.KeySpec{{Flags: command.KeyRO | command.KeyAccess, Index: 1}},
+		Summary: "Returns the score of a member in a sorted set.",
+		Since:   "1.2.0", Group: "sorted-set", Complexity: "O(1)",
+	}, zscoreCommand)
 }
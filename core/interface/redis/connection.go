@@ -3,4 +3,6 @@ package redis
 type Connection interface {
 	GetSelectedDb() int
 	SelectDb(db int)
+	// reads must not update the access time of keys
+	NoTouch() bool
 }
@@ -0,0 +1,11 @@
+package redis
+
+import (
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+type Server interface {
+	Exec(*connection.Connection, [][]byte) protocol.RedisMessage
+	Close()
+}
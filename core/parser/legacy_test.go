@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// The channel based parser replaced by Parser, kept to compare their
+// performance. Inline commands are left out, the benchmarks don't use them.
+
+const legacy_queue_size = 64
+
+type legacyPayload struct {
+	msg protocol.RedisMessage
+	err error
+}
+
+func legacyParse(stream io.Reader) <-chan *legacyPayload {
+	payloads := make(chan *legacyPayload, legacy_queue_size)
+	go func() {
+		defer close(payloads)
+		r := bufio.NewReader(stream)
+		for {
+			buf, err := r.ReadSlice('\n')
+			if err != nil {
+				return
+			}
+			line := bytes.TrimRight(buf, "\r\n")
+			if len(line) == 0 || line[0] != '*' {
+				continue
+			}
+			legacyParseArray(line, r, payloads)
+		}
+	}()
+	return payloads
+}
+
+func legacyParseArray(buf []byte, r *bufio.Reader, ch chan<- *legacyPayload) {
+	l, err := strconv.ParseInt(string(buf[1:]), 10, 32)
+	if err != nil {
+		ch <- &legacyPayload{err: errors.New("protocol error: invalid array header: " + string(buf[1:]))}
+		return
+	}
+	bulks := make([]protocol.RedisMessage, 0)
+	for i := 0; i < int(l); i++ {
+		buf, err := r.ReadSlice('\n')
+		if err != nil {
+			return
+		}
+		if len(buf) <= 2 || buf[len(buf)-2] != '\r' {
+			continue
+		}
+
+		buf = bytes.TrimSuffix(buf, []byte{'\r', '\n'})
+		if buf[0] == '$' {
+			l, err := strconv.ParseInt(string(buf[1:]), 10, 32)
+			if err != nil {
+				ch <- &legacyPayload{err: errors.New("protocol error: invalid bulk header: " + string(buf[1:]))}
+				return
+			}
+			bulk := make([]byte, l)
+			if _, err := io.ReadFull(r, bulk); err != nil {
+				return
+			}
+			bulks = append(bulks, protocol.MakeBulkString(bulk))
+			if _, err := r.ReadSlice('\n'); err != nil {
+				return
+			}
+		}
+	}
+	ch <- &legacyPayload{msg: protocol.MakeArray(bulks)}
+}
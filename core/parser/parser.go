@@ -5,115 +5,191 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"slices"
 	"strconv"
 
-	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/utils"
 )
 
-const chan_size = 64
+const (
+	read_buffer_size = 16 * 1024
+	// maximum size of an inline command or of a length header line
+	max_line_size = 64 * 1024
+	// maximum number of arguments of a command
+	max_multibulk_len = 1024 * 1024
+	// arguments preallocated for a command, more are allocated as they are read
+	max_prealloc_args = 1024
+	// argument buffers grown past this size by a large command
+	// are released instead of being reused
+	max_reused_buffer_size = 64 * 1024
+)
+
+// ProtocolError is returned for malformed requests. The stream can't be
+// parsed further after it, so the connection is meant to be closed.
+type ProtocolError struct {
+	msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.msg
+}
+
+func protocolError(msg string) error {
+	return &ProtocolError{msg: msg}
+}
+
+// Parser reads commands from a stream, both as RESP arrays of bulk
+// strings and as inline commands
+type Parser struct {
+	r *bufio.Reader
+
+	// arguments of the last command, they point into buf
+	args [][]byte
+	buf  []byte
+	// start and end of each argument in buf, which may move while growing
+	bounds []int
+}
+
+func MakeParser(stream io.Reader) *Parser {
+	return &Parser{r: bufio.NewReaderSize(stream, read_buffer_size)}
+}
 
-func Parse(stream io.Reader) <-chan *Payload {
-	payloads := make(chan *Payload, chan_size)
-	go parse(stream, payloads)
-	return payloads
+// Buffered returns the size of the data read from the stream and not parsed yet
+func (p *Parser) Buffered() int {
+	return p.r.Buffered()
 }
 
-func parse(stream io.Reader, payloads chan<- *Payload) {
-	r := bufio.NewReader(stream)
+// ReadCommand reads the next command. The arguments are only valid until
+// the next call, as their buffers are reused. Stream errors are returned
+// as is, a *ProtocolError is returned for a malformed command.
+func (p *Parser) ReadCommand() ([][]byte, error) {
+	if cap(p.buf) > max_reused_buffer_size {
+		p.buf = nil
+	}
+
 	for {
-		buf, err := r.ReadSlice('\n')
+		p.buf, p.bounds = p.buf[:0], p.bounds[:0]
+		line, err := p.readLine()
+		if err == errLineTooLong {
+			return nil, protocolError("too big inline request")
+		}
 		if err != nil {
-			close(payloads)
-			break
+			return nil, err
+		}
+		if len(line) == 0 {
+			continue
 		}
 
-		if len(buf) <= 2 || buf[len(buf)-2] != '\r' {
+		if line[0] == '*' {
+			err = p.readMultiBulk(line)
+		} else {
+			err = p.readInline(line)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(p.bounds) == 0 {
 			continue
 		}
 
-		buf = bytes.TrimSuffix(buf, []byte{'\r', '\n'})
-		switch buf[0] {
-		case '+':
-		case '*':
-			parseArray(buf, r, payloads)
-		case '$':
-			parseBulk(buf, r, payloads)
+		p.args = p.args[:0]
+		for i := 0; i < len(p.bounds); i += 2 {
+			start, end := p.bounds[i], p.bounds[i+1]
+			// capped, so that appending to an argument can't overwrite the next one
+			p.args = append(p.args, p.buf[start:end:end])
 		}
+		return p.args, nil
 	}
 }
 
-func parseArray(buf []byte, r *bufio.Reader, ch chan<- *Payload) error {
-	l, err := strconv.ParseInt(string(buf[1:]), 10, 32)
+var errLineTooLong = errors.New("line too long")
+
+// readLine reads a line without its line ending. Lines longer than the
+// reader's buffer are copied, otherwise the line is only valid until the
+// next read. errLineTooLong is returned for lines over max_line_size.
+func (p *Parser) readLine() ([]byte, error) {
+	line, err := p.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		line = append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			if len(line) > max_line_size {
+				return nil, errLineTooLong
+			}
+			var more []byte
+			more, err = p.r.ReadSlice('\n')
+			line = append(line, more...)
+		}
+	}
+	if len(line) > max_line_size+2 {
+		return nil, errLineTooLong
+	}
 	if err != nil {
-		porotocolError(ch, "invalid array header: "+string(buf[1:]))
-		return nil
+		return nil, err
+	}
+	// inline commands may end with a bare \n
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+func (p *Parser) readMultiBulk(header []byte) error {
+	n, err := strconv.ParseInt(string(header[1:]), 10, 64)
+	if err != nil || n < 0 || n > max_multibulk_len {
+		return protocolError("invalid multibulk length")
 	}
-	bulks := make([]protocol.RedisMessage, 0)
-	for i := 0; i < int(l); i++ {
-		buf, err := r.ReadSlice('\n')
+	// the header is trusted only as far as arguments are actually sent
+	p.bounds = slices.Grow(p.bounds, 2*min(int(n), max_prealloc_args))
+
+	maxBulkLen := config.Properties().ProtoMaxBulkLen
+	for i := 0; i < int(n); i++ {
+		line, err := p.readLine()
+		if err == errLineTooLong {
+			return protocolError("too big bulk count string")
+		}
 		if err != nil {
-			return nil
+			return err
 		}
-
-		if len(buf) <= 2 || buf[len(buf)-2] != '\r' {
-			continue
+		if len(line) == 0 || line[0] != '$' {
+			return protocolError("expected '$', got '" + string(line[:min(len(line), 1)]) + "'")
+		}
+		length, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil || length < 0 || length > maxBulkLen {
+			return protocolError("invalid bulk length")
 		}
 
-		buf = bytes.TrimSuffix(buf, []byte{'\r', '\n'})
-		if buf[0] == '$' {
-			l, err := strconv.ParseInt(string(buf[1:]), 10, 32)
-			if err != nil {
-				porotocolError(ch, "invalid bulk header: "+string(buf[1:]))
-				return nil
-			}
-			length := int(l)
-			bulk, err := readBulk(length, r)
-			if err != nil {
+		// the bulk and its trailing \r\n are read straight into buf. Large
+		// bulks are read in growing chunks, so that memory is only
+		// allocated for data actually sent.
+		start := len(p.buf)
+		end := start + int(length)
+		for len(p.buf) < end+2 {
+			n := len(p.buf)
+			chunk := min(end+2-n, max(n-start, read_buffer_size))
+			p.buf = slices.Grow(p.buf, chunk)[:n+chunk]
+			if _, err := io.ReadFull(p.r, p.buf[n:]); err != nil {
 				return err
 			}
-
-			bulks = append(bulks, protocol.MakeBulkString(bulk))
-
-			_, err = r.ReadSlice('\n')
-			if err != nil {
-				return nil
-			}
 		}
+		if p.buf[end] != '\r' || p.buf[end+1] != '\n' {
+			return protocolError("expected CRLF after bulk")
+		}
+		p.buf = p.buf[:end]
+		p.bounds = append(p.bounds, start, end)
 	}
-
-	ch <- &Payload{msg: protocol.MakeArray(bulks)}
-
 	return nil
 }
 
-func parseBulk(buf []byte, r *bufio.Reader, ch chan<- *Payload) error {
-	l, err := strconv.ParseInt(string(buf[1:]), 10, 32)
+// readInline parses a command sent as a line of space separated
+// arguments, e.g. by telnet. Arguments may be quoted like in redis-cli.
+func (p *Parser) readInline(line []byte) error {
+	args, err := utils.SplitArgs(string(line))
 	if err != nil {
-		porotocolError(ch, "invalid bulk header: "+string(buf[1:]))
-		return nil
+		return protocolError("unbalanced quotes in request")
 	}
-	length := int(l)
 
-	bulk, err := readBulk(length, r)
-	if err != nil {
-		return err
-	}
-	_, err = r.ReadSlice('\n')
-	if err != nil {
-		return err
+	for _, arg := range args {
+		start := len(p.buf)
+		p.buf = append(p.buf, arg...)
+		p.bounds = append(p.bounds, start, len(p.buf))
 	}
-
-	ch <- &Payload{msg: protocol.MakeBulkString(bulk)}
 	return nil
 }
-
-func readBulk(length int, r *bufio.Reader) ([]byte, error) {
-	value := make([]byte, length)
-	_, err := io.ReadFull(r, value)
-	return value, err
-}
-
-func porotocolError(ch chan<- *Payload, msg string) {
-	err := errors.New("protocol error: " + msg)
-	ch <- &Payload{err: err}
-}
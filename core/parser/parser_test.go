@@ -0,0 +1,224 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// parseAll returns the arguments of every command, followed by
+// the protocol error which stops the parsing if any
+func parseAll(input string) []any {
+	results := make([]any, 0)
+	p := MakeParser(strings.NewReader(input))
+	for {
+		args, err := p.ReadCommand()
+		if err == io.EOF {
+			return results
+		}
+		if err != nil {
+			return append(results, err.Error())
+		}
+		strs := make([]string, 0)
+		for _, arg := range args {
+			strs = append(strs, string(arg))
+		}
+		results = append(results, strs)
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	Convey("TestReadCommand", t, func() {
+		Convey("multibulk commands", func() {
+			So(parseAll("*3\r\n$3\r\nset\r\n$1\r\na\r\n$4\r\nb\r\nc\r\n*0\r\n*1\r\n$0\r\n\r\n"), ShouldResemble, []any{
+				[]string{"set", "a", "b\r\nc"}, []string{""},
+			})
+		})
+
+		Convey("malformed commands", func() {
+			So(parseAll("*x\r\n"), ShouldResemble, []any{"Protocol error: invalid multibulk length"})
+			So(parseAll("*1\r\n:1\r\n"), ShouldResemble, []any{"Protocol error: expected '$', got ':'"})
+			So(parseAll("*1\r\n$-1\r\n"), ShouldResemble, []any{"Protocol error: invalid bulk length"})
+			So(parseAll("*1\r\n$1\r\nab\r\n*1\r\n$4\r\nping\r\n"), ShouldResemble, []any{"Protocol error: expected CRLF after bulk"})
+		})
+
+		Convey("length limits", func() {
+			So(parseAll("*-1\r\n"), ShouldResemble, []any{"Protocol error: invalid multibulk length"})
+			So(parseAll("*2147483647\r\n"), ShouldResemble, []any{"Protocol error: invalid multibulk length"})
+			So(parseAll("*1\r\n$536870913\r\n"), ShouldResemble, []any{"Protocol error: invalid bulk length"})
+			So(parseAll("*1\r\n$99999999999999999999\r\n"), ShouldResemble, []any{"Protocol error: invalid bulk length"})
+			So(parseAll("*1\r\n$"+strings.Repeat("1", 70000)+"\r\n"), ShouldResemble, []any{"Protocol error: too big bulk count string"})
+			So(parseAll(strings.Repeat("x", 70000)), ShouldResemble, []any{"Protocol error: too big inline request"})
+		})
+
+		Convey("large bulks are allocated as they are read", func() {
+			p := MakeParser(strings.NewReader("*1\r\n$536870912\r\nabc"))
+			_, err := p.ReadCommand()
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+			So(cap(p.buf), ShouldBeLessThan, 1<<20)
+		})
+
+		Convey("truncated commands", func() {
+			p := MakeParser(strings.NewReader("*2\r\n$3\r\nget\r\n$5\r\nab"))
+			_, err := p.ReadCommand()
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+		})
+
+		Convey("buffers are reused", func() {
+			p := MakeParser(strings.NewReader("*2\r\n$3\r\nget\r\n$1\r\na\r\nget b\r\n"))
+			first, err := p.ReadCommand()
+			So(err, ShouldBeNil)
+			key := first[1]
+			So(cap(first[0]), ShouldEqual, 3)
+
+			second, err := p.ReadCommand()
+			So(err, ShouldBeNil)
+			So(string(second[1]), ShouldEqual, "b")
+			So(string(key), ShouldEqual, "b")
+		})
+	})
+}
+
+func TestParseInline(t *testing.T) {
+	Convey("TestParseInline", t, func() {
+		Convey("inline and multibulk commands", func() {
+			So(parseAll("PING\r\n*2\r\n$3\r\nget\r\n$1\r\na\r\nget b\n"), ShouldResemble, []any{
+				[]string{"PING"}, []string{"get", "a"}, []string{"get", "b"},
+			})
+		})
+
+		Convey("quoted arguments", func() {
+			So(parseAll("set \"a\\x20b\" 'c d' \"\\n\"\r\n"), ShouldResemble, []any{
+				[]string{"set", "a b", "c d", "\n"},
+			})
+		})
+
+		Convey("empty lines are skipped", func() {
+			So(parseAll("\r\n  \r\nping\r\n"), ShouldResemble, []any{[]string{"ping"}})
+		})
+
+		Convey("unbalanced quotes", func() {
+			So(parseAll("ping\r\nset \"a b\r\nping\r\n"), ShouldResemble, []any{
+				[]string{"ping"}, "Protocol error: unbalanced quotes in request",
+			})
+		})
+
+		Convey("lines longer than the read buffer", func() {
+			value := strings.Repeat("x", 10000)
+			So(parseAll("set k "+value+"\r\n"), ShouldResemble, []any{[]string{"set", "k", value}})
+		})
+	})
+}
+
+// encode returns the command as a multibulk request
+func encode(args [][]byte) []byte {
+	req := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		req = append(req, "$"+strconv.Itoa(len(arg))+"\r\n"...)
+		req = append(req, arg...)
+		req = append(req, "\r\n"...)
+	}
+	return req
+}
+
+// FuzzReadCommand checks that any input is either parsed into commands
+// which survive being encoded again, or rejected with a protocol error.
+// The seed corpus is in testdata/fuzz/FuzzReadCommand.
+func FuzzReadCommand(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p := MakeParser(bytes.NewReader(data))
+		for {
+			args, err := p.ReadCommand()
+			if err != nil {
+				var protoErr *ProtocolError
+				if err != io.EOF && err != io.ErrUnexpectedEOF && !errors.As(err, &protoErr) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if len(args) == 0 {
+				t.Fatal("empty command")
+			}
+
+			again, err := MakeParser(bytes.NewReader(encode(args))).ReadCommand()
+			if err != nil {
+				t.Fatalf("encoded command %q can't be parsed: %v", args, err)
+			}
+			if !slices.EqualFunc(args, again, bytes.Equal) {
+				t.Fatalf("command %q parsed as %q once encoded", args, again)
+			}
+		}
+	})
+}
+
+// repeatReader endlessly repeats data
+type repeatReader struct {
+	data []byte
+	off  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.data[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.data)
+	}
+	return n, nil
+}
+
+// stoppableReader repeats data like repeatReader until it is stopped
+type stoppableReader struct {
+	repeatReader
+	stopped atomic.Bool
+}
+
+func (r *stoppableReader) Read(p []byte) (int, error) {
+	if r.stopped.Load() {
+		return 0, io.EOF
+	}
+	return r.repeatReader.Read(p)
+}
+
+// BenchmarkReadCommand compares Parser with the channel based
+// parser it replaced, on the same SET of a 32 bytes value
+func BenchmarkReadCommand(b *testing.B) {
+	cmd := []byte("*3\r\n$3\r\nset\r\n$8\r\nkey:1234\r\n$32\r\n" + strings.Repeat("v", 32) + "\r\n")
+
+	b.Run("pull", func(b *testing.B) {
+		p := MakeParser(&repeatReader{data: cmd})
+		b.SetBytes(int64(len(cmd)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := p.ReadCommand(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("channel", func(b *testing.B) {
+		r := &stoppableReader{repeatReader: repeatReader{data: cmd}}
+		payloads := legacyParse(r)
+		defer func() {
+			r.stopped.Store(true)
+			for range payloads {
+			}
+		}()
+		b.SetBytes(int64(len(cmd)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if p := <-payloads; p.err != nil {
+				b.Fatal(p.err)
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+
+	"github.com/HwHgoo/Gredis/utils/pool"
+)
+
+var crlf = []byte("\r\n")
+
+// Encoder streams messages to a writer, so that a large reply is never
+// materialized. Writes are meant to be buffered, e.g. by a bufio.Writer.
+// The first write error is kept and stops the encoding.
+type Encoder struct {
+	w        io.Writer
+	protover int
+	err      error
+	// formats headers without allocating
+	scratch []byte
+}
+
+var encoders = pool.MakePool(256, func() *Encoder {
+	return &Encoder{scratch: make([]byte, 0, 32)}
+})
+
+// WriteMessage encodes msg to w according to the protocol version
+func WriteMessage(w io.Writer, msg RedisMessage, protover int) error {
+	e := encoders.Get()
+	e.w, e.protover, e.err = w, protover, nil
+	msg.EncodeTo(e)
+	err := e.err
+	e.w = nil
+	encoders.Put(e)
+	return err
+}
+
+// Encode returns the encoded message according to the protocol version
+func Encode(msg RedisMessage, protover int) []byte {
+	var b bytes.Buffer
+	WriteMessage(&b, msg, protover)
+	return b.Bytes()
+}
+
+// Protocol returns the RESP version messages are encoded with
+func (e *Encoder) Protocol() int {
+	return e.protover
+}
+
+func (e *Encoder) Write(p []byte) {
+	if e.err != nil || len(p) == 0 {
+		return
+	}
+	_, e.err = e.w.Write(p)
+}
+
+// WriteHeader writes the type prefix and a length or an integer
+func (e *Encoder) WriteHeader(prefix byte, n int64) {
+	e.scratch = append(e.scratch[:0], prefix)
+	e.scratch = strconv.AppendInt(e.scratch, n, 10)
+	e.scratch = append(e.scratch, '\r', '\n')
+	e.Write(e.scratch)
+}
+
+// WriteLine writes a simple type, whose data can't contain newlines
+func (e *Encoder) WriteLine(prefix byte, data []byte) {
+	e.scratch = append(e.scratch[:0], prefix)
+	e.Write(e.scratch)
+	e.Write(data)
+	e.Write(crlf)
+}
+
+// WriteBlob writes a length prefixed type, like a bulk string
+func (e *Encoder) WriteBlob(prefix byte, data []byte) {
+	e.WriteHeader(prefix, int64(len(data)))
+	e.Write(data)
+	e.Write(crlf)
+}
+
+// WriteAggregate writes an aggregate type of length n, like an array
+func (e *Encoder) WriteAggregate(prefix byte, n int, elements []RedisMessage) {
+	e.WriteHeader(prefix, int64(n))
+	for _, elem := range elements {
+		if e.err != nil {
+			return
+		}
+		elem.EncodeTo(e)
+	}
+}
@@ -0,0 +1,120 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// failingWriter fails once it has been written n bytes
+type failingWriter struct {
+	n      int
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.n -= len(p); w.n < 0 {
+		return 0, errors.New("write failed")
+	}
+	return len(p), nil
+}
+
+func TestWriteMessage(t *testing.T) {
+	Convey("TestWriteMessage", t, func() {
+		msg := MakeArray([]RedisMessage{
+			MakeBulkString([]byte("value")),
+			MakeMap([]RedisMessage{MakeSimpleString([]byte("k")), MakeDouble(2.5)}),
+		})
+
+		Convey("streams the same bytes as Bytes", func() {
+			var b bytes.Buffer
+			So(WriteMessage(&b, msg, RESP2), ShouldBeNil)
+			So(b.String(), ShouldEqual, string(msg.Bytes()))
+		})
+
+		Convey("stops at the first write error", func() {
+			w := &failingWriter{n: 4}
+			So(WriteMessage(w, msg, RESP3), ShouldNotBeNil)
+			So(w.writes, ShouldEqual, 2)
+		})
+	})
+}
+
+// a reply like the one of a large MGET
+func makeBenchmarkReply() RedisMessage {
+	elements := make([]RedisMessage, 1000)
+	for i := range elements {
+		elements[i] = MakeBulkString(bytes.Repeat([]byte(strconv.Itoa(i%10)), 100))
+	}
+	return MakeArray(elements)
+}
+
+// legacyBytes is the recursive Bytes replaced by the Encoder, for the
+// messages of the benchmark reply: each element is materialized, then
+// appended to its parent
+func legacyBytes(msg RedisMessage) []byte {
+	switch m := msg.(type) {
+	case *BulkString:
+		l := len(m.data)
+		bulkLen := []byte(strconv.Itoa(l))
+		b := make([]byte, 1+len(bulkLen)+2+l+2)
+		b[0] = '$'
+		copy(b[1:], bulkLen)
+		offset := 1 + len(bulkLen)
+		b[offset], b[offset+1] = '\r', '\n'
+		offset += 2
+		copy(b[offset:], m.data)
+		b[len(b)-2] = '\r'
+		b[len(b)-1] = '\n'
+		return b
+	case *Array:
+		data := append([]byte{'*'}, strconv.Itoa(len(m.elements))...)
+		data = append(data, '\r', '\n')
+		for _, element := range m.elements {
+			data = append(data, legacyBytes(element)...)
+		}
+		return data
+	}
+	panic("legacyBytes: unsupported message")
+}
+
+// BenchmarkEncode compares the recursive Bytes the encoder replaced,
+// the current Bytes and streaming, on the same reply
+func BenchmarkEncode(b *testing.B) {
+	msg := makeBenchmarkReply()
+	if !bytes.Equal(legacyBytes(msg), msg.Bytes()) {
+		b.Fatal("legacyBytes and Bytes differ")
+	}
+
+	b.Run("recursive", func(b *testing.B) {
+		w := bufio.NewWriter(io.Discard)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.Write(legacyBytes(msg))
+		}
+	})
+
+	// the reply materialized by Bytes, then written
+	b.Run("bytes", func(b *testing.B) {
+		w := bufio.NewWriter(io.Discard)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.Write(msg.Bytes())
+		}
+	})
+
+	// the reply streamed to the buffered writer
+	b.Run("stream", func(b *testing.B) {
+		w := bufio.NewWriter(io.Discard)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			WriteMessage(w, msg, RESP2)
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package protocol
 
+import "strings"
+
 type RedisErrorMessage interface {
 	RedisMessage
 	Error() string
@@ -19,6 +21,11 @@ var (
 	NanError               = redisErrorMessage{[]byte("-ERR result score is not a number (NaN)\r\n")}
 	MinOrMaxNotFloatError  = redisErrorMessage{[]byte("-ERR min or max is not a float\r\n")}
 	DbIndexOutOfRange      = redisErrorMessage{[]byte("-ERR DB index is out of range\r\n")}
+	NoSuchKeyError         = redisErrorMessage{[]byte("-ERR no such key\r\n")}
+	SameObjectError        = redisErrorMessage{[]byte("-ERR source and destination objects are the same\r\n")}
+	MaxClientsReachedError = redisErrorMessage{[]byte("-ERR max number of clients reached\r\n")}
+	NoProtoError           = redisErrorMessage{[]byte("-NOPROTO unsupported protocol version\r\n")}
+	WrongPassError         = redisErrorMessage{[]byte("-WRONGPASS invalid username-password pair or user is disabled.\r\n")}
 
 	ZSetNXAndXXError        = redisErrorMessage{[]byte("-ERR XX and NX options at the same time are not compatible\r\n")}
 	ZSetGTLTAndNXError      = redisErrorMessage{[]byte("-ERR GT, LT, and/or NX options at the same time are not compatible\r\n")}
@@ -27,6 +34,8 @@ var (
 
 func (e redisErrorMessage) Bytes() []byte { return e.msg }
 
+func (e redisErrorMessage) EncodeTo(enc *Encoder) { enc.Write(e.msg) }
+
 func (e redisErrorMessage) Args() [][]byte { return nil }
 
 func (e redisErrorMessage) Error() string { return string(e.msg)[1 : len(e.msg)-2] }
@@ -38,3 +47,11 @@ func MakeUnknownCommandError(cmdName string, startwith string) RedisErrorMessage
 func MakeWrongNumberOfArgError(cmdname string) RedisErrorMessage {
 	return &redisErrorMessage{[]byte("-ERR wrong number of arguments for '" + cmdname + "' command\r\n")}
 }
+
+func MakeGenericError(msg string) RedisErrorMessage {
+	return &redisErrorMessage{[]byte("-ERR " + msg + "\r\n")}
+}
+
+func MakeUnknownSubcommandError(cmdname string, subcmd string) RedisErrorMessage {
+	return &redisErrorMessage{[]byte("-ERR unknown subcommand '" + subcmd + "'. Try " + strings.ToUpper(cmdname) + " HELP.\r\n")}
+}
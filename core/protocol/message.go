@@ -1,23 +1,32 @@
 package protocol
 
-import "strconv"
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/HwHgoo/Gredis/utils"
+)
+
+// protocol versions negotiated by HELLO
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
 
 type RedisMessage interface {
-	Bytes() []byte  // encoded message according to Redis serialization protocol specification
-	Args() [][]byte // decoded arguments for coresponding command (if any)
+	Bytes() []byte     // encoded message according to RESP2, the default protocol
+	EncodeTo(*Encoder) // streams the message according to the encoder's protocol version
+	Args() [][]byte    // decoded arguments for coresponding command (if any)
 }
 
 type SimpleString struct {
 	data []byte
 }
 
-func (ss *SimpleString) Bytes() []byte {
-	b := make([]byte, 1+len(ss.data)+2)
-	b[0] = '+'
-	copy(b[1:], ss.data)
-	b[len(b)-2] = '\r'
-	b[len(b)-1] = '\n'
-	return b
+func (ss *SimpleString) Bytes() []byte { return Encode(ss, RESP2) }
+
+func (ss *SimpleString) EncodeTo(e *Encoder) {
+	e.WriteLine('+', ss.data)
 }
 
 func (ss *SimpleString) Args() [][]byte {
@@ -30,20 +39,10 @@ type BulkString struct {
 	data []byte
 }
 
-func (bs *BulkString) Bytes() []byte {
-	l := len(bs.data)
-	bulkLen := []byte(strconv.Itoa(l))
-	b := make([]byte, 1+len(bulkLen)+2+l+2) // $ + " + {data} + " + \r\n
-	b[0] = '$'
-	copy(b[1:], bulkLen)
-	offset := 1 + len(bulkLen)
-	b[offset], b[offset+1] = '\r', '\n'
-	offset += 2
-	copy(b[offset:], bs.data)
-	offset += len(bs.data)
-	b[len(b)-2] = '\r'
-	b[len(b)-1] = '\n'
-	return b
+func (bs *BulkString) Bytes() []byte { return Encode(bs, RESP2) }
+
+func (bs *BulkString) EncodeTo(e *Encoder) {
+	e.WriteBlob('$', bs.data)
 }
 
 func (bs *BulkString) Args() [][]byte {
@@ -56,44 +55,58 @@ type SimpleError struct {
 	data []byte
 }
 
-func (se *SimpleError) Bytes() []byte {
-	b := make([]byte, 1+len(se.data)+2)
-	b[0] = '-'
-	copy(b[1:], se.data)
-	b[len(b)-2] = '\r'
-	b[len(b)-1] = '\n'
-	return b
+func (se *SimpleError) Bytes() []byte { return Encode(se, RESP2) }
+
+func (se *SimpleError) EncodeTo(e *Encoder) {
+	e.WriteLine('-', se.data)
 }
 
 func (se *SimpleError) Args() [][]byte {
 	return nil
 }
 
+// SimpleNil is the RESP3 null, a null bulk string in RESP2
 type SimpleNil struct {
 	data []byte
 }
 
 var SimpleNilInstance = SimpleNil{[]byte("_\r\n")}
 
-func (sn *SimpleNil) Bytes() []byte { return sn.data }
+func (sn *SimpleNil) Bytes() []byte { return Encode(sn, RESP2) }
+
+func (sn *SimpleNil) EncodeTo(e *Encoder) {
+	if e.Protocol() == RESP2 {
+		e.WriteHeader('$', -1)
+		return
+	}
+	e.Write(sn.data)
+}
 
 func (sn *SimpleNil) Args() [][]byte { return nil }
 
+// NullArray is the RESP3 null, a null array in RESP2
+type NullArray struct{}
+
+func (NullArray) Bytes() []byte { return Encode(NullArray{}, RESP2) }
+
+func (NullArray) EncodeTo(e *Encoder) {
+	if e.Protocol() == RESP2 {
+		e.WriteHeader('*', -1)
+		return
+	}
+	e.Write(SimpleNilInstance.data)
+}
+
+func (NullArray) Args() [][]byte { return nil }
+
 type Array struct {
 	elements []RedisMessage
 }
 
-func (a *Array) Bytes() []byte {
-	length := strconv.Itoa(len(a.elements))
-	data := []byte("*" + length + "\r\n")
-	for i := range a.elements {
-		if a == nil {
-			continue
-		}
-		data = append(data, a.elements[i].Bytes()...)
-	}
+func (a *Array) Bytes() []byte { return Encode(a, RESP2) }
 
-	return data
+func (a *Array) EncodeTo(e *Encoder) {
+	e.WriteAggregate('*', len(a.elements), a.elements)
 }
 
 func (a *Array) Args() [][]byte {
@@ -112,6 +125,175 @@ func (i *Integer) Bytes() []byte {
 	return i.b
 }
 
+func (i *Integer) EncodeTo(e *Encoder) {
+	e.Write(i.b)
+}
+
 func (a *Integer) Args() [][]byte {
 	return nil
 }
+
+// Map holds alternating keys and values, it is a flat array in RESP2
+type Map struct {
+	elements []RedisMessage
+}
+
+func (m *Map) Bytes() []byte { return Encode(m, RESP2) }
+
+func (m *Map) EncodeTo(e *Encoder) {
+	if e.Protocol() == RESP2 {
+		e.WriteAggregate('*', len(m.elements), m.elements)
+		return
+	}
+	e.WriteAggregate('%', len(m.elements)/2, m.elements)
+}
+
+func (m *Map) Args() [][]byte { return nil }
+
+// Double is a floating point number, it is a bulk string in RESP2
+type Double struct {
+	f float64
+}
+
+func (d *Double) Bytes() []byte { return Encode(d, RESP2) }
+
+func (d *Double) EncodeTo(e *Encoder) {
+	f := utils.FloatBytes(d.f)
+	if e.Protocol() == RESP2 {
+		e.WriteBlob('$', f)
+		return
+	}
+	e.WriteLine(',', f)
+}
+
+func (d *Double) Args() [][]byte { return nil }
+
+// Set is an unordered collection, it is an array in RESP2
+type Set struct {
+	elements []RedisMessage
+}
+
+func (st *Set) Bytes() []byte { return Encode(st, RESP2) }
+
+func (st *Set) EncodeTo(e *Encoder) {
+	e.WriteAggregate(utils.TerneryOp[byte](e.Protocol() == RESP2, '*', '~'), len(st.elements), st.elements)
+}
+
+func (st *Set) Args() [][]byte { return nil }
+
+// Boolean is the integer 1 or 0 in RESP2
+type Boolean struct {
+	b bool
+}
+
+func (bl *Boolean) Bytes() []byte { return Encode(bl, RESP2) }
+
+func (bl *Boolean) EncodeTo(e *Encoder) {
+	switch {
+	case e.Protocol() == RESP2:
+		e.WriteHeader(':', int64(utils.TerneryOp(bl.b, 1, 0)))
+	case bl.b:
+		e.Write([]byte("#t\r\n"))
+	default:
+		e.Write([]byte("#f\r\n"))
+	}
+}
+
+func (bl *Boolean) Args() [][]byte { return nil }
+
+// BigNumber is an integer out of the 64 bits range, it is a bulk string in RESP2
+type BigNumber struct {
+	n *big.Int
+}
+
+func (bn *BigNumber) Bytes() []byte { return Encode(bn, RESP2) }
+
+func (bn *BigNumber) EncodeTo(e *Encoder) {
+	n := []byte(bn.n.String())
+	if e.Protocol() == RESP2 {
+		e.WriteBlob('$', n)
+		return
+	}
+	e.WriteLine('(', n)
+}
+
+func (bn *BigNumber) Args() [][]byte { return nil }
+
+// VerbatimString is a text with its format, e.g. txt or mkd,
+// it is a bulk string of the text in RESP2
+type VerbatimString struct {
+	format string
+	data   []byte
+}
+
+func (vs *VerbatimString) Bytes() []byte { return Encode(vs, RESP2) }
+
+func (vs *VerbatimString) EncodeTo(e *Encoder) {
+	if e.Protocol() == RESP2 {
+		e.WriteBlob('$', vs.data)
+		return
+	}
+	e.WriteHeader('=', int64(len(vs.format)+1+len(vs.data)))
+	e.Write([]byte(vs.format + ":"))
+	e.Write(vs.data)
+	e.Write(crlf)
+}
+
+func (vs *VerbatimString) Args() [][]byte { return nil }
+
+// BulkError is an error which may contain newlines, it is
+// a simple error in RESP2 with the newlines replaced by spaces
+type BulkError struct {
+	data []byte
+}
+
+func (be *BulkError) Bytes() []byte { return Encode(be, RESP2) }
+
+func (be *BulkError) EncodeTo(e *Encoder) {
+	if e.Protocol() == RESP2 {
+		data := bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' {
+				return ' '
+			}
+			return r
+		}, be.data)
+		e.WriteLine('-', data)
+		return
+	}
+	e.WriteBlob('!', be.data)
+}
+
+func (be *BulkError) Error() string { return string(be.data) }
+
+func (be *BulkError) Args() [][]byte { return nil }
+
+// Push is out-of-band data like pub/sub messages, it is an array in RESP2
+type Push struct {
+	elements []RedisMessage
+}
+
+func (p *Push) Bytes() []byte { return Encode(p, RESP2) }
+
+func (p *Push) EncodeTo(e *Encoder) {
+	e.WriteAggregate(utils.TerneryOp[byte](e.Protocol() == RESP2, '*', '>'), len(p.elements), p.elements)
+}
+
+func (p *Push) Args() [][]byte { return nil }
+
+// Attribute adds auxiliary data to a reply, the alternating keys and
+// values of the attributes are dropped in RESP2
+type Attribute struct {
+	attributes []RedisMessage
+	msg        RedisMessage
+}
+
+func (at *Attribute) Bytes() []byte { return Encode(at, RESP2) }
+
+func (at *Attribute) EncodeTo(e *Encoder) {
+	if e.Protocol() != RESP2 {
+		e.WriteAggregate('|', len(at.attributes)/2, at.attributes)
+	}
+	at.msg.EncodeTo(e)
+}
+
+func (at *Attribute) Args() [][]byte { return at.msg.Args() }
@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEncode(t *testing.T) {
+	Convey("TestEncode", t, func() {
+		testcases := []struct {
+			name  string
+			msg   RedisMessage
+			resp2 string
+			resp3 string
+		}{
+			{"null", MakeNil(), "$-1\r\n", "_\r\n"},
+			{"null array", MakeNullArray(), "*-1\r\n", "_\r\n"},
+			{"double", MakeDouble(1.5), "$3\r\n1.5\r\n", ",1.5\r\n"},
+			{"infinity", MakeDouble(math.Inf(-1)), "$4\r\n-inf\r\n", ",-inf\r\n"},
+			{"nan", MakeDouble(math.NaN()), "$3\r\nnan\r\n", ",nan\r\n"},
+			{"map", MakeMap([]RedisMessage{MakeBulkString([]byte("a")), MakeInteger(1)}),
+				"*2\r\n$1\r\na\r\n:1\r\n", "%1\r\n$1\r\na\r\n:1\r\n"},
+			{"set", MakeSet([]RedisMessage{MakeInteger(1)}), "*1\r\n:1\r\n", "~1\r\n:1\r\n"},
+			{"boolean", MakeBoolean(true), ":1\r\n", "#t\r\n"},
+			{"big number", MakeBigNumber(new(big.Int).Lsh(big.NewInt(1), 64)),
+				"$20\r\n18446744073709551616\r\n", "(18446744073709551616\r\n"},
+			{"verbatim string", MakeVerbatimString("txt", []byte("a\nb")), "$3\r\na\nb\r\n", "=7\r\ntxt:a\nb\r\n"},
+			{"bulk error", MakeBulkError("ERR a\r\nb"), "-ERR a  b\r\n", "!8\r\nERR a\r\nb\r\n"},
+			{"push", MakePush([]RedisMessage{MakeBulkString([]byte("message"))}),
+				"*1\r\n$7\r\nmessage\r\n", ">1\r\n$7\r\nmessage\r\n"},
+			{"attribute", MakeAttribute([]RedisMessage{MakeBulkString([]byte("ttl")), MakeInteger(3)}, MakeInteger(1)),
+				":1\r\n", "|1\r\n$3\r\nttl\r\n:3\r\n:1\r\n"},
+			{"nested", MakeArray([]RedisMessage{MakeNil(), MakeSimpleString([]byte("OK"))}),
+				"*2\r\n$-1\r\n+OK\r\n", "*2\r\n_\r\n+OK\r\n"},
+		}
+
+		for _, tc := range testcases {
+			Convey(tc.name, func() {
+				So(string(tc.msg.Bytes()), ShouldEqual, tc.resp2)
+				So(string(Encode(tc.msg, RESP2)), ShouldEqual, tc.resp2)
+				So(string(Encode(tc.msg, RESP3)), ShouldEqual, tc.resp3)
+			})
+		}
+	})
+}
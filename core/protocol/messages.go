@@ -6,3 +6,14 @@ var (
 	RedisBgSave         = SimpleString{[]byte("Background saving started")}
 	RedisNotImplemented = SimpleString{[]byte("Command not implemented yet")}
 )
+
+// NoReply is returned by commands which send nothing back, e.g. a successful SHUTDOWN
+var NoReply = noReply{}
+
+type noReply struct{}
+
+func (noReply) Bytes() []byte { return nil }
+
+func (noReply) EncodeTo(*Encoder) {}
+
+func (noReply) Args() [][]byte { return nil }
@@ -1,6 +1,9 @@
 package protocol
 
-import "strconv"
+import (
+	"math/big"
+	"strconv"
+)
 
 func MakeSimpleString(b []byte) RedisMessage {
 	return &SimpleString{
@@ -25,6 +28,52 @@ func MakeArray(elements []RedisMessage) RedisMessage {
 	}
 }
 
+// MakeMap makes a map of alternating keys and values
+func MakeMap(elements []RedisMessage) RedisMessage {
+	return &Map{
+		elements: elements,
+	}
+}
+
+func MakeDouble(f float64) RedisMessage {
+	return &Double{f: f}
+}
+
+func MakeSet(elements []RedisMessage) RedisMessage {
+	return &Set{
+		elements: elements,
+	}
+}
+
+func MakeBoolean(b bool) RedisMessage {
+	return &Boolean{b: b}
+}
+
+func MakeBigNumber(n *big.Int) RedisMessage {
+	return &BigNumber{n: n}
+}
+
+// MakeVerbatimString makes a text of the given three letters format, e.g. txt
+func MakeVerbatimString(format string, b []byte) RedisMessage {
+	return &VerbatimString{format: format, data: b}
+}
+
+func MakeBulkError(msg string) RedisMessage {
+	return &BulkError{data: []byte(msg)}
+}
+
+// MakePush makes out-of-band data, e.g. a pub/sub message
+func MakePush(elements []RedisMessage) RedisMessage {
+	return &Push{
+		elements: elements,
+	}
+}
+
+// MakeAttribute adds the alternating keys and values of attributes to msg
+func MakeAttribute(attributes []RedisMessage, msg RedisMessage) RedisMessage {
+	return &Attribute{attributes: attributes, msg: msg}
+}
+
 func MakeError(err error) RedisMessage {
 	return &SimpleError{
 		data: []byte(err.Error()),
@@ -34,3 +83,9 @@ func MakeError(err error) RedisMessage {
 func MakeNil() RedisMessage {
 	return &SimpleNilInstance
 }
+
+// MakeNullArray makes the null replied instead of an array,
+// e.g. by blocking commands timing out
+func MakeNullArray() RedisMessage {
+	return NullArray{}
+}
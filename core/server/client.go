@@ -0,0 +1,435 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// there is no ACL, every client is authenticated as the default user
+const default_user = "default"
+
+var (
+	clientNameError = protocol.MakeGenericError("Client names cannot contain spaces, newlines or special characters.")
+	noSuchClientErr = protocol.MakeGenericError("No such client")
+	invalidClientId = protocol.MakeGenericError("Invalid client ID")
+)
+
+func unknownTypeError(t string) protocol.RedisMessage {
+	return protocol.MakeGenericError("Unknown client type '" + t + "'")
+}
+
+func registerClientCommands() {
+	connFlags := command.FlagNoScript | command.FlagLoading | command.FlagStale
+	adminFlags := command.FlagAdmin | connFlags
+	registerSubcommand("client", command.Spec{
+		Name: "getname", Arity: 2, Flags: connFlags, Categories: command.CategoryConnection,
+		Help:    []string{"GETNAME", "    Return the name of the current connection."},
+		Summary: "Returns the name of the connection.",
+		Since:   "2.6.9", Group: "connection", Complexity: "O(1)",
+	}, clientGetName)
+	registerSubcommand("client", command.Spec{
+		Name: "id", Arity: 2, Flags: connFlags, Categories: command.CategoryConnection,
+		Help:    []string{"ID", "    Return the ID of the current connection."},
+		Summary: "Returns the unique client ID of the connection.",
+		Since:   "5.0.0", Group: "connection", Complexity: "O(1)",
+	}, clientId)
+	registerSubcommand("client", command.Spec{
+		Name: "info", Arity: 2, Flags: connFlags, Categories: command.CategoryConnection,
+		Help:    []string{"INFO", "    Return information about the current client connection."},
+		Summary: "Returns information about the connection.",
+		Since:   "6.2.0", Group: "connection", Complexity: "O(1)",
+	}, clientSelfInfo)
+	registerSubcommand("client", command.Spec{
+		Name: "kill", Arity: -3, Flags: adminFlags, Categories: command.CategoryConnection,
+		Help: []string{
+			"KILL <ip:port>",
+			"    Kill connection made from <ip:port>.",
+			"KILL <option> <value> [<option> <value> [...]]",
+			"    Kill connections. Options are:",
+			"    * ADDR (<ip:port>|<unixsocket>:0)",
+			"      Kill connections made from the specified address",
+			"    * LADDR (<ip:port>|<unixsocket>:0)",
+			"      Kill connections made to specified local address",
+			"    * TYPE (NORMAL|MASTER|REPLICA|PUBSUB)",
+			"      Kill connections by type.",
+			"    * USER <username>",
+			"      Kill connections authenticated by <username>.",
+			"    * SKIPME (YES|NO)",
+			"      Skip killing current connection (default: yes).",
+			"    * ID <client-id>",
+			"      Kill connections by client id.",
+		},
+		Summary: "Terminates open connections.",
+		Since:   "2.4.0", Group: "connection", Complexity: "O(N) where N is the number of client connections",
+	}, clientKill)
+	registerSubcommand("client", command.Spec{
+		Name: "list", Arity: -2, Flags: adminFlags, Categories: command.CategoryConnection,
+		Help: []string{
+			"LIST [options ...]",
+			"    Return information about client connections. Options:",
+			"    * TYPE (NORMAL|MASTER|REPLICA|PUBSUB)",
+			"      Return clients of specified type.",
+			"    * ID <client-id> [<client-id> ...]",
+			"      Return clients of specified IDs only.",
+		},
+		Summary: "Lists open connections.",
+		Since:   "2.4.0", Group: "connection", Complexity: "O(N) where N is the number of client connections",
+	}, clientList)
+	registerSubcommand("client", command.Spec{
+		Name: "pause", Arity: -3, Flags: adminFlags, Categories: command.CategoryConnection,
+		Help:    []string{"PAUSE <timeout> [WRITE|ALL]", "    Suspend all, or just write, clients for <timeout> milliseconds."},
+		Summary: "Suspends commands processing.",
+		Since:   "3.0.0", Group: "connection", Complexity: "O(1)",
+	}, clientPause)
+	registerSubcommand("client", command.Spec{
+		Name: "unpause", Arity: 2, Flags: adminFlags, Categories: command.CategoryConnection,
+		Help:    []string{"UNPAUSE", "    Stop the current client pause, resuming traffic."},
+		Summary: "Resumes processing commands from paused clients.",
+		Since:   "6.2.0", Group: "connection", Complexity: "O(N) Where N is the number of paused clients",
+	}, clientUnpause)
+	registerSubcommand("client", command.Spec{
+		Name: "reply", Arity: 3, Flags: connFlags, Categories: command.CategoryConnection,
+		Help:    []string{"REPLY (ON|OFF|SKIP)", "    Control the replies sent to the current connection."},
+		Summary: "Instructs the server whether to reply to commands.",
+		Since:   "3.2.0", Group: "connection", Complexity: "O(1)",
+	}, clientReply)
+	registerSubcommand("client", command.Spec{
+		Name: "no-evict", Arity: 3, Flags: adminFlags, Categories: command.CategoryConnection,
+		Help:    []string{"NO-EVICT (ON|OFF)", "    Protect current client connection from eviction."},
+		Summary: "Sets the client eviction mode of the connection.",
+		Since:   "7.0.0", Group: "connection", Complexity: "O(1)",
+	}, clientNoEvict)
+	registerSubcommand("client", command.Spec{
+		Name: "no-touch", Arity: 3, Flags: connFlags, Categories: command.CategoryConnection,
+		Help:    []string{"NO-TOUCH (ON|OFF)", "    Will not touch LRU/LFU stats when this mode is on."},
+		Summary: "Controls whether commands sent by the client affect the LRU/LFU of accessed keys.",
+		Since:   "7.2.0", Group: "connection", Complexity: "O(1)",
+	}, clientNoTouch)
+	registerSubcommand("client", command.Spec{
+		Name: "setname", Arity: 3, Flags: connFlags, Categories: command.CategoryConnection,
+		Help:    []string{"SETNAME <name>", "    Assign the name <name> to the current connection."},
+		Summary: "Sets the connection name.",
+		Since:   "2.6.9", Group: "connection", Complexity: "O(1)",
+	}, clientSetName)
+	registerSubcommand("client", command.Spec{
+		Name: "setinfo", Arity: 4, Flags: connFlags, Categories: command.CategoryConnection,
+		Help: []string{
+			"SETINFO <option> <value>",
+			"    Set client meta attr. Options are:",
+			"    * LIB-NAME: the client lib name.",
+			"    * LIB-VER: the client lib version.",
+		},
+		Summary: "Sets information specific to the client or connection.",
+		Since:   "7.2.0", Group: "connection", Complexity: "O(1)",
+	}, clientSetInfo)
+}
+
+func clientId(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	return protocol.MakeInteger(int64(conn.ID()))
+}
+
+func clientSelfInfo(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	return protocol.MakeVerbatimString("txt", []byte(clientInfo(conn)+"\n"))
+}
+
+func clientUnpause(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	s.pause.stop()
+	return &protocol.RedisOk
+}
+
+func clientNoEvict(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	return clientSwitchFlag(conn, connection.FlagNoEvict, string(args[0]))
+}
+
+func clientNoTouch(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	return clientSwitchFlag(conn, connection.FlagNoTouch, string(args[0]))
+}
+
+func clientSwitchFlag(conn *connection.Connection, flag int, mode string) protocol.RedisMessage {
+	switch strings.ToLower(mode) {
+	case "on":
+		conn.SetFlags(flag)
+	case "off":
+		conn.ClearFlags(flag)
+	default:
+		return &protocol.SyntaxError
+	}
+	return &protocol.RedisOk
+}
+
+func clientSetName(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	name := string(args[0])
+	if !validClientString(name) {
+		return clientNameError
+	}
+	conn.SetName(name)
+	return &protocol.RedisOk
+}
+
+func clientGetName(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	if name := conn.Name(); name != "" {
+		return protocol.MakeBulkString([]byte(name))
+	}
+	return protocol.MakeNil()
+}
+
+// clientReply sets the reply mode, only ON is replied to. SKIP is
+// ignored while replies are off.
+func clientReply(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	switch strings.ToLower(string(args[0])) {
+	case "on":
+		conn.SetReplyMode(connection.ReplyOn)
+		return &protocol.RedisOk
+	case "off":
+		conn.SetReplyMode(connection.ReplyOff)
+	case "skip":
+		if conn.ReplyMode() != connection.ReplyOff {
+			conn.SetReplyMode(connection.ReplySkip)
+		}
+	default:
+		return &protocol.SyntaxError
+	}
+	return protocol.NoReply
+}
+
+// validClientString reports whether s only has printable characters
+// without spaces, as client names are shown in CLIENT LIST
+func validClientString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '!' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func clientSetInfo(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	attr, value := string(args[0]), string(args[1])
+	var set func(string)
+	switch strings.ToLower(attr) {
+	case "lib-name":
+		set = conn.SetLibName
+	case "lib-ver":
+		set = conn.SetLibVersion
+	default:
+		return protocol.MakeGenericError("Unrecognized option '" + attr + "'")
+	}
+
+	if !validClientString(value) {
+		return protocol.MakeGenericError(strings.ToLower(attr) + " cannot contain spaces, newlines or special characters.")
+	}
+	set(value)
+	return &protocol.RedisOk
+}
+
+// clientType parses a client type as given to the TYPE option
+func clientType(t string) (string, bool) {
+	switch strings.ToLower(t) {
+	case "normal", "pubsub", "master":
+		return strings.ToLower(t), true
+	case "replica", "slave":
+		return "replica", true
+	}
+	return "", false
+}
+
+func typeOfClient(conn *connection.Connection) string {
+	switch {
+	case conn.HasFlags(connection.FlagReplica):
+		return "replica"
+	case conn.HasFlags(connection.FlagPubSub):
+		return "pubsub"
+	default:
+		return "normal"
+	}
+}
+
+func clientFlags(conn *connection.Connection) string {
+	flags := ""
+	if conn.HasFlags(connection.FlagReplica) {
+		flags += "S"
+	}
+	if conn.HasFlags(connection.FlagPubSub) {
+		flags += "P"
+	}
+	if conn.HasFlags(connection.FlagBlocked) {
+		flags += "b"
+	}
+	if conn.HasFlags(connection.FlagCloseAfterReply) {
+		flags += "c"
+	}
+	if conn.HasFlags(connection.FlagNoEvict) {
+		flags += "e"
+	}
+	if conn.HasFlags(connection.FlagNoTouch) {
+		flags += "T"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+	return flags
+}
+
+// clientInfo formats a client the way CLIENT LIST and CLIENT INFO show it
+func clientInfo(conn *connection.Connection) string {
+	cmd := conn.LastCommand()
+	if cmd == "" {
+		cmd = "NULL"
+	}
+
+	fields := [][2]string{
+		{"id", strconv.FormatUint(conn.ID(), 10)},
+		{"addr", conn.Addr()},
+		{"laddr", conn.LocalAddr()},
+		{"name", conn.Name()},
+		{"age", strconv.FormatInt(int64(conn.Age().Seconds()), 10)},
+		{"idle", strconv.FormatInt(int64(conn.IdleTime().Seconds()), 10)},
+		{"flags", clientFlags(conn)},
+		{"db", strconv.Itoa(conn.GetSelectedDb())},
+		{"qbuf", strconv.Itoa(conn.QueryBufferSize())},
+		{"omem", strconv.Itoa(conn.OutputBufferSize())},
+		{"cmd", cmd},
+		{"user", default_user},
+		{"resp", strconv.Itoa(conn.Protocol())},
+		{"lib-name", conn.LibName()},
+		{"lib-ver", conn.LibVersion()},
+	}
+
+	b := strings.Builder{}
+	for i, field := range fields {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(field[0])
+		b.WriteByte('=')
+		b.WriteString(field[1])
+	}
+	return b.String()
+}
+
+// CLIENT LIST [TYPE type] [ID id [id ...]]
+func clientList(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	typ := ""
+	var ids map[uint64]bool
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); {
+		case opt == "type" && i+1 < len(args):
+			t, ok := clientType(string(args[i+1]))
+			if !ok {
+				return unknownTypeError(string(args[i+1]))
+			}
+			typ = t
+			i++
+		case opt == "id" && i+1 < len(args):
+			ids = make(map[uint64]bool)
+			for i++; i < len(args); i++ {
+				id, err := strconv.ParseUint(string(args[i]), 10, 64)
+				if err != nil || id == 0 {
+					return invalidClientId
+				}
+				ids[id] = true
+			}
+		default:
+			return &protocol.SyntaxError
+		}
+	}
+
+	b := strings.Builder{}
+	for _, c := range s.clients.List() {
+		if (typ != "" && typeOfClient(c) != typ) || (ids != nil && !ids[c.ID()]) {
+			continue
+		}
+		b.WriteString(clientInfo(c))
+		b.WriteByte('\n')
+	}
+	return protocol.MakeVerbatimString("txt", []byte(b.String()))
+}
+
+// CLIENT KILL addr, or CLIENT KILL <filter> <value> ... which
+// replies with the number of clients killed
+func clientKill(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	if len(args) == 1 {
+		addr := string(args[0])
+		for _, c := range s.clients.List() {
+			if c.Addr() == addr {
+				killClient(conn, c)
+				return &protocol.RedisOk
+			}
+		}
+		return noSuchClientErr
+	}
+	if len(args)%2 != 0 {
+		return &protocol.SyntaxError
+	}
+
+	var (
+		id          uint64
+		addr, laddr string
+		typ         string
+		skipme      = true
+	)
+	for i := 0; i < len(args); i += 2 {
+		value := string(args[i+1])
+		switch strings.ToLower(string(args[i])) {
+		case "id":
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || n == 0 {
+				return invalidClientId
+			}
+			id = n
+		case "addr":
+			addr = value
+		case "laddr":
+			laddr = value
+		case "type":
+			t, ok := clientType(value)
+			if !ok {
+				return unknownTypeError(value)
+			}
+			typ = t
+		case "user":
+			// every client is the default user, so it filters nothing out
+			if value != default_user {
+				return protocol.MakeGenericError("No such user '" + value + "'")
+			}
+		case "skipme":
+			switch strings.ToLower(value) {
+			case "yes":
+				skipme = true
+			case "no":
+				skipme = false
+			default:
+				return &protocol.SyntaxError
+			}
+		default:
+			return &protocol.SyntaxError
+		}
+	}
+
+	killed := 0
+	for _, c := range s.clients.List() {
+		if (id != 0 && c.ID() != id) ||
+			(addr != "" && c.Addr() != addr) ||
+			(laddr != "" && c.LocalAddr() != laddr) ||
+			(typ != "" && typeOfClient(c) != typ) ||
+			(skipme && c == conn) {
+			continue
+		}
+		killClient(conn, c)
+		killed++
+	}
+	return protocol.MakeInteger(int64(killed))
+}
+
+// killClient closes c, the current client is closed after it's replied to
+func killClient(current, c *connection.Connection) {
+	if c == current {
+		c.SetFlags(connection.FlagCloseAfterReply)
+		return
+	}
+	c.Close()
+}
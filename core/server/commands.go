@@ -8,27 +8,103 @@ import (
 	"github.com/HwHgoo/Gredis/core/protocol"
 )
 
-func commandBgSave(conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+// specs of the commands handled specially, kept so that
+// they are still recognized once renamed
+var shutdownSpec, clientUnpauseSpec *command.Spec
+
+type CommandExecutor func(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage
+
+func register(spec command.Spec, exec CommandExecutor) {
+	command.Register(spec, command.ServerExecutor(exec))
+}
+
+func registerSubcommand(parent string, spec command.Spec, exec CommandExecutor) {
+	command.RegisterSubcommand(parent, spec, command.ServerExecutor(exec))
+}
+
+func commandBgSave(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
 	return &protocol.RedisNotImplemented
 }
 
-func commandSelect(conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+// PING [message]
+func commandPing(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	switch len(args) {
+	case 0:
+		return protocol.MakeSimpleString([]byte("PONG"))
+	case 1:
+		return protocol.MakeBulkString(args[0])
+	}
+	return protocol.MakeWrongNumberOfArgError("ping")
+}
+
+func commandSelect(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
 	db := string(args[0])
 	dbno, err := strconv.ParseInt(db, 10, 32)
 	if err != nil {
 		return &protocol.InvalidIntegerError
 	}
 
-	if dbno < 0 || dbno > 16 {
+	if dbno < 0 || dbno >= int64(len(s.databases)) {
 		return &protocol.DbIndexOutOfRange
 	}
 	conn.SelectDb(int(dbno))
 	return &protocol.RedisOk
 }
 
-var register = command.Register[command.ServerCommandExecutor]
-
 func init() {
-	register("bgsave", 1, commandBgSave)
-	register("select", 2, commandSelect)
+	register(command.Spec{
+		Name: "ping", Arity: -1, Flags: command.FlagFast, Categories: command.CategoryConnection,
+		Summary: "Returns the server's liveliness response.",
+		Since:   "1.0.0", Group: "connection", Complexity: "O(1)",
+	}, commandPing)
+	register(command.Spec{
+		Name: "bgsave", Arity: 1, Flags: command.FlagAdmin | command.FlagNoScript,
+		Summary: "Asynchronously saves the database(s) to disk.",
+		Since:   "1.0.0", Group: "server", Complexity: "O(1)",
+	}, commandBgSave)
+	register(command.Spec{
+		Name: "select", Arity: 2, Flags: command.FlagLoading | command.FlagStale | command.FlagFast, Categories: command.CategoryConnection,
+		Summary: "Changes the selected database.",
+		Since:   "1.0.0", Group: "connection", Complexity: "O(1)",
+	}, commandSelect)
+	register(command.Spec{
+		Name: "config", Arity: -2,
+		Summary: "A container for server configuration commands.",
+		Since:   "2.0.0", Group: "server", Complexity: "Depends on subcommand.",
+	}, nil)
+	register(command.Spec{
+		Name: "shutdown", Arity: -1, Flags: command.FlagAdmin | command.FlagNoScript | command.FlagLoading | command.FlagStale,
+		Summary: "Synchronously saves the database(s) to disk and shuts down the Redis server.",
+		Since:   "1.0.0", Group: "server", Complexity: "O(N) when saving, where N is the total number of keys in all databases when saving data, otherwise O(1)",
+	}, commandShutdown)
+	register(command.Spec{
+		Name: "info", Arity: -1, Flags: command.FlagLoading | command.FlagStale, Categories: command.CategoryDangerous,
+		Summary: "Returns information and statistics about the server.",
+		Since:   "1.0.0", Group: "server", Complexity: "O(1)",
+	}, commandInfo)
+	register(command.Spec{
+		Name: "client", Arity: -2, Categories: command.CategoryConnection,
+		Summary: "A container for client connection commands.",
+		Since:   "2.4.0", Group: "connection", Complexity: "Depends on subcommand.",
+	}, nil)
+	register(command.Spec{
+		Name: "hello", Arity: -1, Flags: command.FlagNoScript | command.FlagLoading | command.FlagStale | command.FlagFast, Categories: command.CategoryConnection,
+		Summary: "Handshakes with the Redis server.",
+		Since:   "6.0.0", Group: "connection", Complexity: "O(1)",
+	}, commandHello)
+	register(command.Spec{
+		Name: "command", Arity: -1, Flags: command.FlagLoading | command.FlagStale, Categories: command.CategoryConnection,
+		Help:    []string{"(no subcommand)", "    Return details about all commands."},
+		Summary: "Returns detailed information about all commands.",
+		Since:   "2.8.13", Group: "server", Complexity: "O(N) where N is the total number of Redis commands",
+	}, commandCommand)
+
+	registerClientCommands()
+	registerConfigCommands()
+	registerCommandCommands()
+	registerKeyspaceCommands()
+	registerModuleCommands()
+
+	shutdownSpec = command.Lookup("shutdown")
+	clientUnpauseSpec = command.Lookup("client").Subcommand("unpause")
 }
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+func registerConfigCommands() {
+	flags := command.FlagAdmin | command.FlagNoScript | command.FlagLoading | command.FlagStale
+	registerSubcommand("config", command.Spec{
+		Name: "get", Arity: -3, Flags: flags,
+		Help:    []string{"GET <pattern>", "    Return parameters matching the glob-like <pattern> and their values."},
+		Summary: "Returns the effective values of configuration parameters.",
+		Since:   "2.0.0", Group: "server", Complexity: "O(N) when N is the number of configuration parameters provided",
+	}, configGet)
+	registerSubcommand("config", command.Spec{
+		Name: "set", Arity: -4, Flags: flags,
+		Help:    []string{"SET <directive> <value>", "    Set the configuration <directive> to <value>."},
+		Summary: "Sets configuration parameters in-flight.",
+		Since:   "2.0.0", Group: "server", Complexity: "O(N) when N is the number of configuration parameters provided",
+	}, configSet)
+	registerSubcommand("config", command.Spec{
+		Name: "resetstat", Arity: 2, Flags: flags,
+		Help:    []string{"RESETSTAT", "    Reset statistics reported by the INFO command."},
+		Summary: "Resets the server's statistics.",
+		Since:   "2.0.0", Group: "server", Complexity: "O(1)",
+	}, configResetStat)
+	registerSubcommand("config", command.Spec{
+		Name: "rewrite", Arity: 2, Flags: flags,
+		Help:    []string{"REWRITE", "    Rewrite the configuration file."},
+		Summary: "Persists the effective configuration to file.",
+		Since:   "2.8.0", Group: "server", Complexity: "O(1)",
+	}, configRewrite)
+}
+
+// CONFIG GET pattern [pattern ...]
+func configGet(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	patterns := make([]string, 0, len(args))
+	for _, arg := range args {
+		patterns = append(patterns, string(arg))
+	}
+	pairs := config.Get(patterns...)
+	elements := make([]protocol.RedisMessage, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		elements = append(elements,
+			protocol.MakeBulkString([]byte(pair[0])),
+			protocol.MakeBulkString([]byte(pair[1])))
+	}
+	return protocol.MakeArray(elements)
+}
+
+// CONFIG SET directive value [directive value ...]
+func configSet(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	if len(args)%2 != 0 {
+		return protocol.MakeWrongNumberOfArgError("config|set")
+	}
+	pairs := make([][2]string, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		pairs = append(pairs, [2]string{string(args[i]), string(args[i+1])})
+	}
+	if err := config.Set(pairs); err != nil {
+		return protocol.MakeGenericError(err.Error())
+	}
+	return &protocol.RedisOk
+}
+
+func configRewrite(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	if err := config.Rewrite(); err == config.ErrNoConfigFile {
+		return protocol.MakeGenericError(err.Error())
+	} else if err != nil {
+		return protocol.MakeGenericError("Rewriting config file: " + err.Error())
+	}
+	return &protocol.RedisOk
+}
+
+func configResetStat(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	s.stats.reset()
+	command.ResetStats()
+	return &protocol.RedisOk
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func commandHello(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	protover := conn.Protocol()
+	if len(args) > 0 {
+		v, err := strconv.ParseInt(string(args[0]), 10, 64)
+		if err != nil {
+			return protocol.MakeGenericError("Protocol version is not an integer or out of range")
+		}
+		if v != protocol.RESP2 && v != protocol.RESP3 {
+			return &protocol.NoProtoError
+		}
+		protover = int(v)
+	}
+
+	name, setName := "", false
+	for i := 1; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); {
+		case opt == "auth" && i+2 < len(args):
+			// there is no ACL, only the default user without password exists
+			if string(args[i+1]) != default_user {
+				return &protocol.WrongPassError
+			}
+			i += 2
+		case opt == "setname" && i+1 < len(args):
+			name, setName = string(args[i+1]), true
+			if !validClientString(name) {
+				return clientNameError
+			}
+			i++
+		default:
+			return protocol.MakeGenericError("Syntax error in HELLO option '" + string(args[i]) + "'")
+		}
+	}
+
+	conn.SetProtocol(protover)
+	if setName {
+		conn.SetName(name)
+	}
+
+	return protocol.MakeMap([]protocol.RedisMessage{
+		protocol.MakeBulkString([]byte("server")), protocol.MakeBulkString([]byte("redis")),
+		protocol.MakeBulkString([]byte("version")), protocol.MakeBulkString([]byte(redis_version)),
+		protocol.MakeBulkString([]byte("proto")), protocol.MakeInteger(int64(protover)),
+		protocol.MakeBulkString([]byte("id")), protocol.MakeInteger(int64(conn.ID())),
+		protocol.MakeBulkString([]byte("mode")), protocol.MakeBulkString([]byte("standalone")),
+		protocol.MakeBulkString([]byte("role")), protocol.MakeBulkString([]byte("master")),
+		protocol.MakeBulkString([]byte("modules")), protocol.MakeArray([]protocol.RedisMessage{}),
+	})
+}
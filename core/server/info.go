@@ -0,0 +1,146 @@
+package server
+
+import (
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+const redis_version = "7.2.0"
+
+type infoSection struct {
+	name string
+	// whether the section is part of INFO without arguments
+	isDefault bool
+	fields    func(s *Server) [][2]string
+}
+
+var infoSections = []infoSection{
+	{"server", true, infoServer},
+	{"clients", true, infoClients},
+	{"stats", true, infoStats},
+	{"commandstats", false, infoCommandStats},
+	{"errorstats", true, infoErrorStats},
+}
+
+func infoServer(s *Server) [][2]string {
+	uptime := time.Since(s.startTime)
+	return [][2]string{
+		{"redis_version", redis_version},
+		{"redis_mode", "standalone"},
+		{"os", runtime.GOOS + " " + runtime.GOARCH},
+		{"go_version", runtime.Version()},
+		{"process_id", strconv.Itoa(os.Getpid())},
+		{"tcp_port", strconv.Itoa(config.Properties().Port)},
+		{"uptime_in_seconds", strconv.FormatInt(int64(uptime.Seconds()), 10)},
+		{"uptime_in_days", strconv.FormatInt(int64(uptime.Hours()/24), 10)},
+	}
+}
+
+func infoClients(s *Server) [][2]string {
+	return [][2]string{
+		{"connected_clients", strconv.FormatInt(s.stats.ConnectedClients.Load(), 10)},
+		{"maxclients", strconv.Itoa(config.Properties().MaxClients)},
+	}
+}
+
+func infoStats(s *Server) [][2]string {
+	return [][2]string{
+		{"total_connections_received", strconv.FormatInt(s.stats.ConnectionsReceived.Load(), 10)},
+		{"total_commands_processed", strconv.FormatInt(s.stats.CommandsProcessed.Load(), 10)},
+		{"rejected_connections", strconv.FormatInt(s.stats.RejectedConnections.Load(), 10)},
+		{"client_idle_timeout_disconnections", strconv.FormatInt(s.stats.IdleTimeoutDisconnections.Load(), 10)},
+		{"client_output_buffer_limit_disconnections", strconv.FormatInt(s.stats.OutputBufferLimitDisconnections.Load(), 10)},
+		{"total_error_replies", strconv.FormatInt(command.ErrorCount(), 10)},
+	}
+}
+
+// infoCommandStats lists the commands called at least once, successfully or not
+func infoCommandStats(s *Server) [][2]string {
+	fields := make([][2]string, 0)
+	for _, spec := range s.allCommands() {
+		st := spec.Stats()
+		calls, usec, rejected, failed := st.Calls.Load(), st.Usec.Load(), st.RejectedCalls.Load(), st.FailedCalls.Load()
+		if calls == 0 && rejected == 0 && failed == 0 {
+			continue
+		}
+		perCall := 0.0
+		if calls > 0 {
+			perCall = float64(usec) / float64(calls)
+		}
+		fields = append(fields, [2]string{"cmdstat_" + spec.FullName(),
+			"calls=" + strconv.FormatInt(calls, 10) +
+				",usec=" + strconv.FormatInt(usec, 10) +
+				",usec_per_call=" + strconv.FormatFloat(perCall, 'f', 2, 64) +
+				",rejected_calls=" + strconv.FormatInt(rejected, 10) +
+				",failed_calls=" + strconv.FormatInt(failed, 10)})
+	}
+	return fields
+}
+
+func infoErrorStats(s *Server) [][2]string {
+	counts := command.ErrorStats()
+	prefixes := make([]string, 0, len(counts))
+	for prefix := range counts {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.Sort(prefixes)
+
+	fields := make([][2]string, len(prefixes))
+	for i, prefix := range prefixes {
+		fields[i] = [2]string{"errorstat_" + prefix, "count=" + strconv.FormatInt(counts[prefix], 10)}
+	}
+	return fields
+}
+
+// INFO [section [section ...]]
+func commandInfo(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	all, everything := false, false
+	wanted := make(map[string]bool)
+	for _, arg := range args {
+		section := strings.ToLower(string(arg))
+		switch section {
+		case "all":
+			all = true
+		case "everything":
+			everything = true
+		case "default":
+			for _, is := range infoSections {
+				if is.isDefault {
+					wanted[is.name] = true
+				}
+			}
+		default:
+			wanted[section] = true
+		}
+	}
+	if len(args) == 0 {
+		for _, is := range infoSections {
+			wanted[is.name] = is.isDefault
+		}
+	}
+
+	var b strings.Builder
+	for _, is := range infoSections {
+		if !all && !everything && !wanted[is.name] {
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.ToUpper(is.name[:1]) + is.name[1:] + "\r\n")
+		for _, field := range is.fields(s) {
+			b.WriteString(field[0] + ":" + field[1] + "\r\n")
+		}
+	}
+	return protocol.MakeVerbatimString("txt", []byte(b.String()))
+}
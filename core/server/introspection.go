@@ -0,0 +1,254 @@
+package server
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/utils"
+)
+
+func registerCommandCommands() {
+	flags := command.FlagLoading | command.FlagStale
+	registerSubcommand("command", command.Spec{
+		Name: "count", Arity: 2, Flags: flags, Categories: command.CategoryConnection,
+		Help:    []string{"COUNT", "    Return the total number of commands in this server."},
+		Summary: "Returns a count of commands.",
+		Since:   "2.8.13", Group: "server", Complexity: "O(1)",
+	}, commandCount)
+	registerSubcommand("command", command.Spec{
+		Name: "list", Arity: -2, Flags: flags, Categories: command.CategoryConnection,
+		Help: []string{
+			"LIST [FILTERBY (MODULE <module-name>|ACLCAT <category>|PATTERN <pattern>)]",
+			"    Return a list of all commands in this server.",
+		},
+		Summary: "Returns a list of command names.",
+		Since:   "7.0.0", Group: "server", Complexity: "O(N) where N is the total number of Redis commands",
+	}, commandList)
+	registerSubcommand("command", command.Spec{
+		Name: "info", Arity: -2, Flags: flags, Categories: command.CategoryConnection,
+		Help: []string{
+			"INFO [<command-name> ...]",
+			"    Return details about multiple commands.",
+			"    If no command names are given, documentation details for all",
+			"    commands are returned.",
+		},
+		Summary: "Returns information about one, multiple or all commands.",
+		Since:   "2.8.13", Group: "server", Complexity: "O(N) where N is the number of commands to look up",
+	}, commandInfoSubcommand)
+	registerSubcommand("command", command.Spec{
+		Name: "docs", Arity: -2, Flags: flags, Categories: command.CategoryConnection,
+		Help: []string{
+			"DOCS [<command-name> ...]",
+			"    Return documentation details about multiple commands.",
+			"    If no command names are given, documentation details for all",
+			"    commands are returned.",
+		},
+		Summary: "Returns documentary information about one, multiple or all commands.",
+		Since:   "7.0.0", Group: "server", Complexity: "O(N) where N is the number of commands to look up",
+	}, commandDocs)
+	registerSubcommand("command", command.Spec{
+		Name: "getkeys", Arity: -3, Flags: flags, Categories: command.CategoryConnection,
+		Help:    []string{"GETKEYS <full-command>", "    Return the keys from a full command."},
+		Summary: "Extracts the key names from an arbitrary command.",
+		Since:   "2.8.13", Group: "server", Complexity: "O(N) where N is the number of arguments to the command",
+	}, commandGetKeys)
+}
+
+// COMMAND returns the details of all the commands
+func commandCommand(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	return commandSpecInfos(s.commands())
+}
+
+func commandCount(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	return protocol.MakeInteger(int64(len(s.commands())))
+}
+
+// COMMAND INFO [command ...]
+func commandInfoSubcommand(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	if len(args) == 0 {
+		return commandSpecInfos(s.commands())
+	}
+	return commandSpecInfos(s.lookupCommands(args))
+}
+
+// lookupCommands returns the specs of the named commands, nil for unknown
+// ones. Subcommands are named like command|subcommand.
+func (s *Server) lookupCommands(names [][]byte) []*command.Spec {
+	specs := make([]*command.Spec, len(names))
+	for i, name := range names {
+		cmd, sub, found := strings.Cut(strings.ToLower(string(name)), "|")
+		spec := s.lookup(cmd)
+		if spec != nil && found {
+			spec = spec.Subcommand(sub)
+		}
+		specs[i] = spec
+	}
+	return specs
+}
+
+// allCommands returns the specs of the commands followed by their subcommands
+func (s *Server) allCommands() []*command.Spec {
+	specs := make([]*command.Spec, 0)
+	for _, spec := range s.commands() {
+		specs = append(specs, spec)
+		specs = append(specs, spec.Subcommands()...)
+	}
+	return specs
+}
+
+func simpleStrings(strs []string, prefix string) []protocol.RedisMessage {
+	elements := make([]protocol.RedisMessage, len(strs))
+	for i, s := range strs {
+		elements[i] = protocol.MakeSimpleString([]byte(prefix + s))
+	}
+	return elements
+}
+
+func commandSpecInfos(specs []*command.Spec) protocol.RedisMessage {
+	infos := make([]protocol.RedisMessage, len(specs))
+	for i, spec := range specs {
+		if spec == nil {
+			infos[i] = protocol.MakeNil()
+			continue
+		}
+		infos[i] = commandSpecInfo(spec)
+	}
+	return protocol.MakeArray(infos)
+}
+
+// commandSpecInfo returns the name, arity, flags, legacy key range, ACL
+// categories, tips, key specs and subcommands of the command
+func commandSpecInfo(spec *command.Spec) protocol.RedisMessage {
+	first, last, step := spec.KeyRange()
+	keySpecs := make([]protocol.RedisMessage, len(spec.Keys))
+	for i, k := range spec.Keys {
+		keySpecs[i] = keySpecInfo(k)
+	}
+	subs := make([]protocol.RedisMessage, 0)
+	for _, sub := range spec.Subcommands() {
+		subs = append(subs, commandSpecInfo(sub))
+	}
+
+	return protocol.MakeArray([]protocol.RedisMessage{
+		protocol.MakeBulkString([]byte(spec.FullName())),
+		protocol.MakeInteger(int64(spec.Arity)),
+		protocol.MakeSet(simpleStrings(spec.FlagNames(), "")),
+		protocol.MakeInteger(int64(first)),
+		protocol.MakeInteger(int64(last)),
+		protocol.MakeInteger(int64(step)),
+		protocol.MakeSet(simpleStrings(spec.CategoryNames(), "@")),
+		protocol.MakeArray(nil),
+		protocol.MakeArray(keySpecs),
+		protocol.MakeArray(subs),
+	})
+}
+
+func bulkPair(key string, value protocol.RedisMessage) []protocol.RedisMessage {
+	return []protocol.RedisMessage{protocol.MakeBulkString([]byte(key)), value}
+}
+
+func keySpecInfo(k command.KeySpec) protocol.RedisMessage {
+	beginSearch := slices.Concat(
+		bulkPair("type", protocol.MakeBulkString([]byte("index"))),
+		bulkPair("spec", protocol.MakeMap(bulkPair("index", protocol.MakeInteger(int64(k.Index))))),
+	)
+	findKeys := slices.Concat(
+		bulkPair("type", protocol.MakeBulkString([]byte("range"))),
+		bulkPair("spec", protocol.MakeMap(slices.Concat(
+			bulkPair("lastkey", protocol.MakeInteger(int64(k.LastKey))),
+			bulkPair("keystep", protocol.MakeInteger(int64(max(k.Step, 1)))),
+			bulkPair("limit", protocol.MakeInteger(0)),
+		))),
+	)
+	return protocol.MakeMap(slices.Concat(
+		bulkPair("flags", protocol.MakeSet(simpleStrings(k.FlagNames(), ""))),
+		bulkPair("begin_search", protocol.MakeMap(beginSearch)),
+		bulkPair("find_keys", protocol.MakeMap(findKeys)),
+	))
+}
+
+// COMMAND LIST [FILTERBY MODULE name|ACLCAT category|PATTERN pattern]
+func commandList(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	match := func(spec *command.Spec) bool { return true }
+	if len(args) != 0 {
+		if len(args) != 3 || !strings.EqualFold(string(args[0]), "filterby") {
+			return &protocol.SyntaxError
+		}
+		arg := string(args[2])
+		switch strings.ToLower(string(args[1])) {
+		case "module":
+			match = func(spec *command.Spec) bool { return spec.Module != "" && strings.EqualFold(spec.Module, arg) }
+		case "aclcat":
+			match = func(spec *command.Spec) bool {
+				return slices.ContainsFunc(spec.CategoryNames(), func(name string) bool { return strings.EqualFold(name, arg) })
+			}
+		case "pattern":
+			match = func(spec *command.Spec) bool { return utils.GlobMatch(arg, spec.FullName(), true) }
+		default:
+			return &protocol.SyntaxError
+		}
+	}
+
+	names := make([]protocol.RedisMessage, 0)
+	for _, spec := range s.allCommands() {
+		if match(spec) {
+			names = append(names, protocol.MakeBulkString([]byte(spec.FullName())))
+		}
+	}
+	return protocol.MakeArray(names)
+}
+
+// COMMAND DOCS [command ...]
+func commandDocs(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	specs := s.commands()
+	if len(args) > 0 {
+		specs = slices.DeleteFunc(s.lookupCommands(args), func(spec *command.Spec) bool { return spec == nil })
+	}
+	return commandSpecDocs(specs)
+}
+
+func commandSpecDocs(specs []*command.Spec) protocol.RedisMessage {
+	docs := make([]protocol.RedisMessage, 0, 2*len(specs))
+	for _, spec := range specs {
+		doc := make([]protocol.RedisMessage, 0)
+		for _, field := range [][2]string{
+			{"summary", spec.Summary}, {"since", spec.Since}, {"group", spec.Group}, {"complexity", spec.Complexity}, {"module", spec.Module},
+		} {
+			if field[1] != "" {
+				doc = append(doc, bulkPair(field[0], protocol.MakeBulkString([]byte(field[1])))...)
+			}
+		}
+		if subs := spec.Subcommands(); len(subs) > 0 {
+			doc = append(doc, bulkPair("subcommands", commandSpecDocs(subs))...)
+		}
+		docs = append(docs, bulkPair(spec.FullName(), protocol.MakeMap(doc))...)
+	}
+	return protocol.MakeMap(docs)
+}
+
+// COMMAND GETKEYS command [arg ...]
+func commandGetKeys(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	spec := s.lookup(strings.ToLower(string(args[0])))
+	if spec != nil && len(args) > 1 && len(spec.Subcommands()) > 0 {
+		spec = spec.Subcommand(strings.ToLower(string(args[1])))
+	}
+	if spec == nil {
+		return protocol.MakeGenericError("Invalid command specified")
+	}
+	if !spec.ValidateArity(args) {
+		return protocol.MakeGenericError("Invalid number of arguments specified for command")
+	}
+
+	positions := spec.KeyPositions(args)
+	if len(positions) == 0 {
+		return protocol.MakeGenericError("The command has no key arguments")
+	}
+	keys := make([]protocol.RedisMessage, len(positions))
+	for i, pos := range positions {
+		keys[i] = protocol.MakeBulkString(args[pos])
+	}
+	return protocol.MakeArray(keys)
+}
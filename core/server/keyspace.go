@@ -0,0 +1,91 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/db"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// commands across databases, they are server commands to reach every database
+
+func registerKeyspaceCommands() {
+	register(command.Spec{
+		Name: "copy", Arity: -3, Flags: command.FlagWrite | command.FlagDenyOOM, Categories: command.CategoryKeyspace,
+		Keys: []command.KeySpec{
+			{Flags: command.KeyRO | command.KeyAccess, Index: 1},
+			{Flags: command.KeyOW | command.KeyUpdate, Index: 2},
+		},
+		Summary: "Copies the value of a key to a new key.",
+		Since:   "6.2.0", Group: "generic", Complexity: "O(N) worst case for collections, where N is the number of nested items. O(1) for string values.",
+	}, commandCopy)
+	register(command.Spec{
+		Name: "move", Arity: 3, Flags: command.FlagWrite | command.FlagFast, Categories: command.CategoryKeyspace,
+		Keys:    []command.KeySpec{{Flags: command.KeyRW | command.KeyAccess | command.KeyDelete, Index: 1}},
+		Summary: "Moves a key to another database.",
+		Since:   "1.0.0", Group: "generic", Complexity: "O(1)",
+	}, commandMove)
+}
+
+// database returns the database of index dbno
+func (s *Server) database(dbno string) (*db.Database, int, protocol.RedisErrorMessage) {
+	n, err := strconv.ParseInt(dbno, 10, 32)
+	if err != nil {
+		return nil, 0, &protocol.InvalidIntegerError
+	}
+	if n < 0 || n >= int64(len(s.databases)) {
+		return nil, 0, &protocol.DbIndexOutOfRange
+	}
+	return s.databases[n], int(n), nil
+}
+
+// COPY source destination [DB destination-db] [REPLACE]
+func commandCopy(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	src, dst := string(args[0]), string(args[1])
+	srcDb := conn.GetSelectedDb()
+	dstDb := srcDb
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "replace":
+			replace = true
+		case "db":
+			if i+1 == len(args) {
+				return &protocol.SyntaxError
+			}
+			_, n, err := s.database(string(args[i+1]))
+			if err != nil {
+				return err
+			}
+			dstDb = n
+			i++
+		default:
+			return &protocol.SyntaxError
+		}
+	}
+	if srcDb == dstDb && src == dst {
+		return &protocol.SameObjectError
+	}
+
+	copied, err := s.databases[srcDb].CopyTo(src, s.databases[dstDb], dst, replace, !conn.NoTouch())
+	if err != nil {
+		return err
+	}
+	return protocol.MakeInteger(int64(copied))
+}
+
+// MOVE key db
+func commandMove(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	dst, n, err := s.database(string(args[1]))
+	if err != nil {
+		return err
+	}
+	if n == conn.GetSelectedDb() {
+		return &protocol.SameObjectError
+	}
+	moved := s.databases[conn.GetSelectedDb()].MoveTo(string(args[0]), dst)
+	return protocol.MakeInteger(int64(moved))
+}
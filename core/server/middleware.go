@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// Handler executes a command found by command.Find. The arguments are
+// copied from the parser's buffers, so they may be kept past the call.
+type Handler func(c *connection.Connection, spec *command.Spec, args [][]byte) protocol.RedisMessage
+
+// Middleware intercepts commands before their execution. It may reply on
+// its own without calling next, or call next and observe its reply. Once a
+// middleware is used, the arguments of every command are copied for it.
+type Middleware func(next Handler) Handler
+
+// Use adds middlewares around the execution of commands. They are called
+// in the order they were added, after the server's own ones, e.g. the one
+// holding paused clients. It must be called before serving clients.
+func (s *Server) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+	s.handler = s.call
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		s.handler = s.middlewares[i](s.handler)
+	}
+}
+
+// Reject counts a command refused by a middleware, as
+// reported by INFO commandstats, and returns the reply
+func Reject(spec *command.Spec, reply protocol.RedisMessage) protocol.RedisMessage {
+	spec.Stats().RejectedCalls.Add(1)
+	return reply
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/module"
+)
+
+func registerModuleCommands() {
+	register(command.Spec{
+		Name: "module", Arity: -2,
+		Summary: "A container for module commands.",
+		Since:   "4.0.0", Group: "server", Complexity: "Depends on subcommand.",
+	}, nil)
+	registerSubcommand("module", command.Spec{
+		Name: "list", Arity: 2, Flags: command.FlagAdmin | command.FlagNoScript,
+		Help:    []string{"LIST", "    Return a list of loaded modules."},
+		Summary: "Returns all loaded modules.",
+		Since:   "4.0.0", Group: "server", Complexity: "O(N) where N is the number of loaded modules.",
+	}, moduleList)
+}
+
+// MODULE LIST, modules are linked in the binary so their path and arguments are empty
+func moduleList(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	infos := make([]protocol.RedisMessage, len(s.modules))
+	for i, m := range s.modules {
+		infos[i] = protocol.MakeMap(slices.Concat(
+			bulkPair("name", protocol.MakeBulkString([]byte(m.Name))),
+			bulkPair("ver", protocol.MakeInteger(int64(m.Version))),
+			bulkPair("path", protocol.MakeBulkString(nil)),
+			bulkPair("args", protocol.MakeArray(nil)),
+		))
+	}
+	return protocol.MakeArray(infos)
+}
+
+// loaded tells whether the server loaded the module, which
+// is the case of the empty name of built-in commands
+func (s *Server) loaded(name string) bool {
+	return name == "" || slices.ContainsFunc(s.modules, func(m *module.Module) bool { return m.Name == name })
+}
+
+// commands returns the specs of the commands known to the server sorted by name
+func (s *Server) commands() []*command.Spec {
+	return slices.DeleteFunc(command.List(), func(spec *command.Spec) bool { return !s.loaded(spec.Module) })
+}
+
+// lookup returns the spec of the command if it is known to the server, or nil
+func (s *Server) lookup(name string) *command.Spec {
+	if spec := command.Lookup(name); spec != nil && s.loaded(spec.Module) {
+		return spec
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+const (
+	pause_none = iota
+	// only write commands are held
+	pause_write
+	// every command is held
+	pause_all
+)
+
+var serverClosingError = protocol.MakeGenericError("Server is shutting down")
+
+// pause holds the commands of clients paused by CLIENT PAUSE
+type pause struct {
+	lock sync.Mutex
+	mode int
+	end  time.Time
+	// ends the pause once its timeout is reached
+	timer *time.Timer
+	// closed when the pause ends, nil while not paused
+	unpaused chan struct{}
+	// closed when the server closes, so that held commands return
+	closed chan struct{}
+	// number of commands held
+	held atomic.Int64
+}
+
+func makePause() *pause {
+	return &pause{closed: make(chan struct{})}
+}
+
+// start pauses clients until end. While paused already, the pause
+// is only extended and made more restrictive.
+func (p *pause) start(mode int, end time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.unpaused == nil {
+		p.unpaused = make(chan struct{})
+	}
+	p.mode = max(p.mode, mode)
+	if end.After(p.end) {
+		p.end = end
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		p.timer = time.AfterFunc(time.Until(end), p.stop)
+	}
+}
+
+// stop resumes the paused clients
+func (p *pause) stop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.unpaused == nil {
+		return
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	close(p.unpaused)
+	p.unpaused = nil
+	p.mode = pause_none
+	p.end = time.Time{}
+}
+
+// holdPaused is the middleware holding the commands of paused clients
+func (s *Server) holdPaused(next Handler) Handler {
+	return func(c *connection.Connection, spec *command.Spec, args [][]byte) protocol.RedisMessage {
+		if !s.pause.wait(c, spec) {
+			return Reject(spec, serverClosingError)
+		}
+		return next(c, spec, args)
+	}
+}
+
+// wait holds the command until clients are unpaused. CLIENT UNPAUSE and
+// SHUTDOWN pass even while every command is held, so that the pause can
+// be ended. It returns false if the server closed meanwhile.
+func (p *pause) wait(c *connection.Connection, spec *command.Spec) bool {
+	if c.HasFlags(connection.FlagReplica) || spec == shutdownSpec || spec == clientUnpauseSpec {
+		return true
+	}
+
+	flushed := false
+	for {
+		p.lock.Lock()
+		mode, unpaused := p.mode, p.unpaused
+		p.lock.Unlock()
+		if mode == pause_none || (mode == pause_write && spec.Flags&command.FlagWrite == 0) {
+			return true
+		}
+
+		if !flushed {
+			c.Flush()
+			flushed = true
+			p.held.Add(1)
+			defer p.held.Add(-1)
+		}
+		select {
+		case <-unpaused:
+		case <-p.closed:
+			return false
+		}
+	}
+}
+
+// Held returns the number of commands held by CLIENT PAUSE. They have not
+// started, so a shutdown doesn't wait for them.
+func (s *Server) Held() int64 {
+	return s.pause.held.Load()
+}
+
+func (p *pause) close() {
+	close(p.closed)
+}
+
+// CLIENT PAUSE timeout [WRITE|ALL]
+func clientPause(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	if len(args) > 2 {
+		return &protocol.SyntaxError
+	}
+
+	timeout, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return protocol.MakeGenericError("timeout is not an integer or out of range")
+	}
+	if timeout < 0 {
+		return protocol.MakeGenericError("timeout is negative")
+	}
+
+	mode := pause_all
+	if len(args) == 2 {
+		switch strings.ToLower(string(args[1])) {
+		case "write":
+			mode = pause_write
+		case "all":
+		default:
+			return &protocol.SyntaxError
+		}
+	}
+
+	s.pause.start(mode, time.Now().Add(time.Duration(timeout)*time.Millisecond))
+	return &protocol.RedisOk
+}
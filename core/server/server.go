@@ -1,57 +1,139 @@
 package server
 
 import (
+	"cmp"
 	"log"
-	"strings"
+	"slices"
+	"time"
 
+	"github.com/HwHgoo/Gredis/config"
 	"github.com/HwHgoo/Gredis/connection"
 	"github.com/HwHgoo/Gredis/core/command"
 	"github.com/HwHgoo/Gredis/core/db"
 	"github.com/HwHgoo/Gredis/core/protocol"
-)
-
-const (
-	db_num = 16
+	"github.com/HwHgoo/Gredis/module"
 )
 
 // Redis server
 type Server struct {
-	databases [db_num]*db.Database
+	databases []*db.Database
+	clients   *connection.Registry
+	pause     *pause
+	stats     Stats
+	startTime time.Time
+
+	middlewares []Middleware
+	// number of middlewares added by MakeServer, they don't keep arguments
+	ownMiddlewares int
+	// the middlewares chained around call
+	handler Handler
+
+	shutdownHandler ShutdownHandler
+
+	// modules loaded by the server sorted by name, the commands
+	// of other modules are unknown to it
+	modules []*module.Module
 }
 
-func MakeServer() *Server {
-	server := &Server{}
-	for i := 0; i < db_num; i++ {
+// MakeServer makes a server loading the registered modules along the given ones
+func MakeServer(modules ...*module.Module) (*Server, error) {
+	modules = slices.Concat(module.Registered(), modules)
+	if err := module.Load(modules...); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(modules, func(a, b *module.Module) int { return cmp.Compare(a.Name, b.Name) })
+
+	server := &Server{
+		modules:   slices.Compact(modules),
+		databases: make([]*db.Database, config.Properties().Databases),
+		clients:   connection.MakeRegistry(),
+		pause:     makePause(),
+		startTime: time.Now(),
+	}
+	for i := range server.databases {
 		server.databases[i] = db.MakeDatabase()
 	}
-	return server
+	server.Use(server.holdPaused)
+	server.ownMiddlewares = len(server.middlewares)
+	return server, nil
 }
 
 func (s *Server) Exec(c *connection.Connection, args [][]byte) protocol.RedisMessage {
-	cmdName := strings.ToLower(string(args[0]))
-	if !command.Exists(cmdName) {
-		return protocol.MakeUnknownCommandError(cmdName, argStartWith(args[1:]))
+	spec, errReply := command.Find(args)
+	if spec != nil && !s.loaded(spec.Module) {
+		spec, errReply = nil, command.MakeUnknownCommandError(args)
+	}
+	if errReply != nil {
+		if spec != nil {
+			Reject(spec, errReply)
+		}
+		command.CountError(errReply)
+		return errReply
+	}
+
+	// the parser reuses the argument buffers for the next command
+	if s.mayKeepArgs(spec) {
+		args = cloneArgs(args)
+	}
+	reply := s.handler(c, spec, args)
+	if errReply, ok := reply.(protocol.RedisErrorMessage); ok {
+		command.CountError(errReply)
 	}
+	return reply
+}
 
-	if !command.ValidateArity(cmdName, args) {
-		return protocol.MakeWrongNumberOfArgError(cmdName)
+// call executes the command past the middlewares and records its statistics
+func (s *Server) call(c *connection.Connection, spec *command.Spec, args [][]byte) protocol.RedisMessage {
+	s.stats.CommandsProcessed.Add(1)
+	c.SetLastCommand(spec.FullName())
+	start := time.Now()
+	reply := s.execute(c, spec, args)
+	stats := spec.Stats()
+	stats.Calls.Add(1)
+	stats.Usec.Add(time.Since(start).Microseconds())
+	if _, ok := reply.(protocol.RedisErrorMessage); ok {
+		stats.FailedCalls.Add(1)
 	}
+	return reply
+}
 
-	if command.IsServerCommand(cmdName) {
-		return command.ExecServerCommand(cmdName, c, args[1:])
+func (s *Server) execute(c *connection.Connection, spec *command.Spec, args [][]byte) protocol.RedisMessage {
+	if command.IsServerCommand(spec.FullName()) {
+		return command.ExecServerCommand(spec, s, c, args)
 	}
 	db := s.databases[c.GetSelectedDb()]
 	return db.Exec(c, args)
 }
 
+// Clients returns the registry of the connected clients
+func (s *Server) Clients() *connection.Registry {
+	return s.clients
+}
+
 func (s *Server) Close() {
 	log.Println("Redis server closing.")
+	s.pause.close()
 }
 
-func argStartWith(args [][]byte) string {
-	if len(args) == 0 || len(args[0]) == 0 {
-		return ""
-	}
+// mayKeepArgs tells whether the arguments may be used past the call: only
+// the built-in database commands which don't write are known not to keep them
+func (s *Server) mayKeepArgs(spec *command.Spec) bool {
+	return len(s.middlewares) > s.ownMiddlewares || spec.Module != "" ||
+		spec.Flags&command.FlagWrite != 0 || command.IsServerCommand(spec.FullName())
+}
 
-	return string(args[0][0])
+// cloneArgs copies the arguments into a single allocation
+func cloneArgs(args [][]byte) [][]byte {
+	size := 0
+	for _, arg := range args {
+		size += len(arg)
+	}
+	buf := make([]byte, 0, size)
+	clone := make([][]byte, len(args))
+	for i, arg := range args {
+		start := len(buf)
+		buf = append(buf, arg...)
+		clone[i] = buf[start:len(buf):len(buf)]
+	}
+	return clone
 }
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+const (
+	ShutdownNoSave = 1 << iota
+	ShutdownSave
+	ShutdownNow
+	ShutdownForce
+	ShutdownAbort
+)
+
+// ShutdownHandler is installed by whoever serves the server. It blocks until
+// the server has been drained and returns nil if the process is about to exit.
+type ShutdownHandler func(flags int) error
+
+func (s *Server) SetShutdownHandler(handler ShutdownHandler) {
+	s.shutdownHandler = handler
+}
+
+// Persist saves the dataset before exiting. There is no persistence in
+// Gredis yet, so nothing is saved and only an explicit SAVE fails.
+func (s *Server) Persist(flags int) error {
+	if flags&ShutdownSave != 0 {
+		return errors.New("persistence is not supported yet, can't SAVE")
+	}
+	return nil
+}
+
+// IsShutdown reports whether args invoke SHUTDOWN, which may have been renamed
+func IsShutdown(args [][]byte) bool {
+	return strings.EqualFold(string(args[0]), shutdownSpec.Name) && command.Lookup(shutdownSpec.Name) == shutdownSpec
+}
+
+// SHUTDOWN [NOSAVE|SAVE] [NOW] [FORCE] [ABORT]
+func commandShutdown(s *Server, conn *connection.Connection, args [][]byte) protocol.RedisMessage {
+	flags := 0
+	for _, arg := range args {
+		switch strings.ToLower(string(arg)) {
+		case "nosave":
+			flags |= ShutdownNoSave
+		case "save":
+			flags |= ShutdownSave
+		case "now":
+			flags |= ShutdownNow
+		case "force":
+			flags |= ShutdownForce
+		case "abort":
+			flags |= ShutdownAbort
+		default:
+			return &protocol.SyntaxError
+		}
+	}
+
+	if (flags&ShutdownNoSave != 0 && flags&ShutdownSave != 0) ||
+		(flags&ShutdownAbort != 0 && flags != ShutdownAbort) {
+		return &protocol.SyntaxError
+	}
+
+	if s.shutdownHandler == nil {
+		return protocol.MakeGenericError("SHUTDOWN is not supported by this server")
+	}
+
+	if err := s.shutdownHandler(flags); err != nil {
+		return protocol.MakeGenericError(err.Error())
+	}
+
+	if flags&ShutdownAbort != 0 {
+		return &protocol.RedisOk
+	}
+	// the connection is closed as the server exits
+	return protocol.NoReply
+}
@@ -0,0 +1,29 @@
+package server
+
+import "sync/atomic"
+
+// Stats are server wide counters reported by INFO
+type Stats struct {
+	CommandsProcessed   atomic.Int64
+	ConnectionsReceived atomic.Int64
+	RejectedConnections atomic.Int64
+	// clients closed for being idle longer than timeout
+	IdleTimeoutDisconnections atomic.Int64
+	// clients closed for exceeding client-output-buffer-limit
+	OutputBufferLimitDisconnections atomic.Int64
+
+	// gauges are not reset by CONFIG RESETSTAT
+	ConnectedClients atomic.Int64
+}
+
+func (st *Stats) reset() {
+	st.CommandsProcessed.Store(0)
+	st.ConnectionsReceived.Store(0)
+	st.RejectedConnections.Store(0)
+	st.IdleTimeoutDisconnections.Store(0)
+	st.OutputBufferLimitDisconnections.Store(0)
+}
+
+func (s *Server) Stats() *Stats {
+	return &s.stats
+}
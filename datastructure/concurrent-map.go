@@ -3,11 +3,10 @@ package datastructure
 import (
 	"sync"
 
+	"github.com/HwHgoo/Gredis/config"
 	"github.com/HwHgoo/Gredis/utils"
 )
 
-const shard_count = 32
-
 type ConcurrentMap[T any] []concurrentMapShard[T]
 
 type concurrentMapShard[T any] struct {
@@ -16,7 +15,7 @@ type concurrentMapShard[T any] struct {
 }
 
 func MakeNewConcurrentMap[T any]() *ConcurrentMap[T] {
-	cm := make(ConcurrentMap[T], shard_count)
+	cm := make(ConcurrentMap[T], config.Properties().ShardCount)
 	for i := range cm {
 		cm[i] = concurrentMapShard[T]{m: make(map[string]T)}
 	}
@@ -47,6 +46,6 @@ func (cm *ConcurrentMap[T]) Set(key string, value T) {
 
 func (cm ConcurrentMap[T]) shard(key string) *concurrentMapShard[T] {
 	hash := utils.Fnv32([]byte(key))
-	s := hash % uint32(shard_count)
+	s := hash % uint32(len(cm))
 	return &cm[s]
 }
@@ -9,6 +9,7 @@ type ZSet interface {
 	NthInRange(zrange *ZRangeSpec, n int) SkipListNode
 	GetRange(start, end float64) []string
 	Card() int
+	Copy() ZSet
 }
 
 type zset struct {
@@ -98,3 +99,12 @@ func (z *zset) GetRange(start, end float64) []string {
 func (z *zset) Card() int {
 	return len(z.m)
 }
+
+// Copy returns a deep copy of the set
+func (z *zset) Copy() ZSet {
+	set := NewZSet()
+	for member, score := range z.m {
+		set.Insert(member, score)
+	}
+	return set
+}
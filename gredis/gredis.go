@@ -0,0 +1,227 @@
+// Package gredis runs Gredis inside a Go program, e.g. as an in-process
+// cache or in place of a real Redis in tests.
+//
+//	s, _ := gredis.New(gredis.WithConfig("databases", "4"))
+//	defer s.Close()
+//	lsn, _ := net.Listen("tcp", "127.0.0.1:0")
+//	go s.Serve(lsn)
+//	reply, err := s.Do(ctx, "SET", "key", "value")
+//
+// The configuration of Gredis is global to the process, so only one
+// server may be open at a time.
+package gredis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/core/server"
+	"github.com/HwHgoo/Gredis/module"
+	"github.com/HwHgoo/Gredis/tcpserver"
+	"github.com/HwHgoo/Gredis/utils"
+)
+
+var (
+	ErrServerClosed  = errors.New("gredis: server closed")
+	ErrNoCommand     = errors.New("gredis: no command given")
+	ErrRenameCommand = errors.New("gredis: rename-command isn't supported, use command.Rename")
+	ErrServerOpen    = errors.New("gredis: another server is open")
+)
+
+// set while a server is open, as the configuration is global
+var open atomic.Bool
+
+type settings struct {
+	// lines of configuration, as in redis.conf
+	config      []string
+	middlewares []server.Middleware
+	modules     []*module.Module
+}
+
+type Option func(*settings)
+
+// WithConfig sets an option as in redis.conf, e.g. WithConfig("maxclients", "100").
+// New starts from the defaults and Close restores the configuration New replaced.
+func WithConfig(name string, values ...string) Option {
+	return func(st *settings) {
+		line := name
+		for _, value := range values {
+			line += " " + utils.QuoteArg(value)
+		}
+		st.config = append(st.config, line)
+	}
+}
+
+// WithMiddleware adds middlewares around the execution of commands,
+// see server.Server.Use
+func WithMiddleware(middlewares ...server.Middleware) Option {
+	return func(st *settings) {
+		st.middlewares = append(st.middlewares, middlewares...)
+	}
+}
+
+// WithModule loads modules along the ones registered with module.Register,
+// other servers don't execute their commands unless loading them too
+func WithModule(modules ...*module.Module) Option {
+	return func(st *settings) {
+		st.modules = append(st.modules, modules...)
+	}
+}
+
+type Server struct {
+	tcp *tcpserver.Server
+	// the client executing the commands of Do
+	client *connection.Connection
+	// held by the call of Do whose command is executing, so that
+	// calls take turns on the client
+	turn chan struct{}
+	// the configuration found by New, restored by Close
+	saved  *config.ServerProperties
+	closed atomic.Bool
+}
+
+// New makes a server with an empty dataset. It only serves clients
+// once Serve or ServeConn is called, Do may be called right away.
+// It fails with ErrServerOpen until the previous server is closed.
+func New(options ...Option) (*Server, error) {
+	if !open.CompareAndSwap(false, true) {
+		return nil, ErrServerOpen
+	}
+	st := &settings{}
+	for _, option := range options {
+		option(st)
+	}
+	saved := config.Properties()
+	if err := configure(st.config); err != nil {
+		_ = config.Restore(saved)
+		open.Store(false)
+		return nil, err
+	}
+
+	tcp, err := tcpserver.MakeTcpServer(st.modules...)
+	if err != nil {
+		_ = config.Restore(saved)
+		open.Store(false)
+		return nil, err
+	}
+
+	s := &Server{
+		tcp:    tcp,
+		client: connection.MakeFakeConnection(),
+		turn:   make(chan struct{}, 1),
+		saved:  saved,
+	}
+	s.tcp.Redis().Use(st.middlewares...)
+	return s, nil
+}
+
+// configure replaces the configuration with the defaults along the given lines
+func configure(lines []string) error {
+	if err := config.Restore(nil); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	if err := config.Load("", strings.Join(lines, "\n")); err != nil {
+		return err
+	}
+	// commands are registered once per process, renaming them again
+	// for every server would fail
+	if len(config.Properties().RenamedCommands) > 0 {
+		return ErrRenameCommand
+	}
+	return nil
+}
+
+// Serve serves clients on the listeners, e.g. net.Listen("tcp", "127.0.0.1:0"),
+// until Close or a SHUTDOWN command. It may only be called once.
+func (s *Server) Serve(listeners ...net.Listener) error {
+	if s.closed.Load() {
+		return ErrServerClosed
+	}
+	return s.tcp.Serve(listeners, nil)
+}
+
+// ServeConn serves a single connection, e.g. an end of net.Pipe, until
+// it is closed. It may be called along Serve, or without it.
+func (s *Server) ServeConn(conn net.Conn) {
+	if s.closed.Load() {
+		conn.Close()
+		return
+	}
+	s.tcp.ServeConn(conn)
+}
+
+// Do executes a command without going through the network. The reply is
+// returned along an error if it is an error reply. The commands of Do are
+// executed by a single client, so that e.g. SELECT applies to later calls,
+// and concurrent calls take turns.
+//
+// Once started, a command isn't cancelled with ctx: Do returns ctx.Err()
+// while e.g. CLIENT PAUSE holds the command, which is executed when
+// released, and later calls wait for it.
+func (s *Server) Do(ctx context.Context, args ...string) (protocol.RedisMessage, error) {
+	if s.closed.Load() {
+		return nil, ErrServerClosed
+	}
+	if len(args) == 0 {
+		return nil, ErrNoCommand
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	argv := make([][]byte, len(args))
+	for i, arg := range args {
+		argv[i] = []byte(arg)
+	}
+	select {
+	case s.turn <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var reply protocol.RedisMessage
+	if ctx.Done() == nil {
+		reply = s.tcp.Redis().Exec(s.client, argv)
+		<-s.turn
+	} else {
+		// commands may be held, e.g. by CLIENT PAUSE
+		result := make(chan protocol.RedisMessage, 1)
+		go func() {
+			defer func() { <-s.turn }()
+			result <- s.tcp.Redis().Exec(s.client, argv)
+		}()
+		select {
+		case reply = <-result:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if errReply, ok := reply.(protocol.RedisErrorMessage); ok {
+		return reply, errReply
+	}
+	return reply, nil
+}
+
+// Close stops serving and closes the connections. Commands in-flight are
+// given shutdown-timeout to finish, the dataset isn't saved. The
+// configuration found by New is restored.
+func (s *Server) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return ErrServerClosed
+	}
+	err := s.tcp.Close()
+	if restoreErr := config.Restore(s.saved); err == nil {
+		err = restoreErr
+	}
+	open.Store(false)
+	return err
+}
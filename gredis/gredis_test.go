@@ -0,0 +1,270 @@
+package gredis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/connection"
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/core/server"
+	"github.com/HwHgoo/Gredis/module"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func request(conn net.Conn, r *bufio.Reader, req string) string {
+	conn.SetDeadline(time.Now().Add(time.Second))
+	_, err := conn.Write([]byte(req))
+	So(err, ShouldBeNil)
+	line, err := r.ReadString('\n')
+	So(err, ShouldBeNil)
+	return line
+}
+
+func TestServer(t *testing.T) {
+	Convey("TestServer", t, func() {
+		defer config.Load("", "databases 16")
+		s, err := New(WithConfig("databases", "4"))
+		So(err, ShouldBeNil)
+		defer s.Close()
+		ctx := context.Background()
+
+		Convey("do", func() {
+			reply, err := s.Do(ctx, "SET", "k", "v")
+			So(err, ShouldBeNil)
+			So(string(reply.Bytes()), ShouldEqual, "+OK\r\n")
+			reply, err = s.Do(ctx, "get", "k")
+			So(err, ShouldBeNil)
+			So(string(reply.Bytes()), ShouldEqual, "$1\r\nv\r\n")
+
+			reply, err = s.Do(ctx, "select", "4")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "ERR DB index is out of range")
+			So(reply.Bytes(), ShouldResemble, []byte("-ERR DB index is out of range\r\n"))
+			_, err = s.Do(ctx)
+			So(err, ShouldEqual, ErrNoCommand)
+		})
+
+		Convey("do with a deadline", func() {
+			_, err := s.Do(ctx, "client", "pause", "10000", "all")
+			So(err, ShouldBeNil)
+			timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			_, err = s.Do(timeout, "set", "k", "held")
+			So(err, ShouldEqual, context.DeadlineExceeded)
+
+			// the held command keeps its turn, another client unpauses
+			client, conn := net.Pipe()
+			defer client.Close()
+			go s.ServeConn(conn)
+			So(request(client, bufio.NewReader(client), "client unpause\r\n"), ShouldEqual, "+OK\r\n")
+			reply, err := s.Do(ctx, "get", "k")
+			So(err, ShouldBeNil)
+			So(string(reply.Bytes()), ShouldEqual, "$4\r\nheld\r\n")
+		})
+
+		Convey("concurrent calls take turns", func() {
+			s.Do(ctx, "set", "n", "0")
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						s.Do(ctx, "incr", "n")
+					}
+				}()
+			}
+			wg.Wait()
+			reply, err := s.Do(ctx, "get", "n")
+			So(err, ShouldBeNil)
+			So(string(reply.Bytes()), ShouldEqual, "$3\r\n800\r\n")
+		})
+
+		Convey("serve on a listener", func() {
+			lsn, err := net.Listen("tcp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			done := make(chan error, 1)
+			go func() { done <- s.Serve(lsn) }()
+
+			conn, err := net.Dial("tcp", lsn.Addr().String())
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			So(request(conn, r, "SET k v\r\n"), ShouldEqual, "+OK\r\n")
+			reply, err := s.Do(ctx, "get", "k")
+			So(err, ShouldBeNil)
+			So(string(reply.Bytes()), ShouldEqual, "$1\r\nv\r\n")
+
+			So(s.Close(), ShouldBeNil)
+			So(<-done, ShouldBeNil)
+			_, err = r.ReadString('\n')
+			So(err, ShouldNotBeNil)
+			_, err = net.Dial("tcp", lsn.Addr().String())
+			So(err, ShouldNotBeNil)
+			_, err = s.Do(ctx, "get", "k")
+			So(err, ShouldEqual, ErrServerClosed)
+		})
+
+		Convey("serve a pipe", func() {
+			client, conn := net.Pipe()
+			done := make(chan struct{})
+			go func() {
+				s.ServeConn(conn)
+				close(done)
+			}()
+
+			r := bufio.NewReader(client)
+			So(request(client, r, "*3\r\n$3\r\nset\r\n$1\r\nk\r\n$1\r\nv\r\n"), ShouldEqual, "+OK\r\n")
+			reply, err := s.Do(ctx, "get", "k")
+			So(err, ShouldBeNil)
+			So(string(reply.Bytes()), ShouldEqual, "$1\r\nv\r\n")
+
+			So(s.Close(), ShouldBeNil)
+			<-done
+			So(s.Close(), ShouldEqual, ErrServerClosed)
+			So(s.Serve(), ShouldEqual, ErrServerClosed)
+		})
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	Convey("TestMiddleware", t, func() {
+		calls := 0
+		counter := func(next server.Handler) server.Handler {
+			return func(c *connection.Connection, spec *command.Spec, args [][]byte) protocol.RedisMessage {
+				calls++
+				return next(c, spec, args)
+			}
+		}
+		s, err := New(WithMiddleware(counter))
+		So(err, ShouldBeNil)
+
+		_, err = s.Do(context.Background(), "ping")
+		So(err, ShouldBeNil)
+		So(calls, ShouldEqual, 1)
+		So(s.Close(), ShouldBeNil)
+
+		_, err = New(WithConfig("databases", "0"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestConfig(t *testing.T) {
+	Convey("TestConfig", t, func() {
+		defer config.Load("", "databases 16")
+		So(config.Load("", "databases 8"), ShouldBeNil)
+
+		s, err := New(WithConfig("maxclients", "100"))
+		So(err, ShouldBeNil)
+		So(config.Properties().Databases, ShouldEqual, 16)
+		So(config.Properties().MaxClients, ShouldEqual, 100)
+		_, err = New(WithConfig("maxclients", "10"))
+		So(err, ShouldEqual, ErrServerOpen)
+		So(config.Properties().MaxClients, ShouldEqual, 100)
+		So(s.Close(), ShouldBeNil)
+		So(config.Properties().Databases, ShouldEqual, 8)
+		So(config.Properties().MaxClients, ShouldEqual, 10000)
+
+		_, err = New(WithConfig("rename-command", "get", "fetch"))
+		So(err, ShouldEqual, ErrRenameCommand)
+		So(config.Properties().Databases, ShouldEqual, 8)
+		So(command.Lookup("get"), ShouldNotBeNil)
+	})
+}
+
+func TestModule(t *testing.T) {
+	Convey("TestModule", t, func() {
+		var stack *module.Type
+		// arguments kept by stack.keep
+		var kept [][]byte
+		m := &module.Module{Name: "stack", Version: 1, OnLoad: func(ctx *module.Context) error {
+			stack = &module.Type{
+				Name:        "stack-typ",
+				MemoryUsage: func(value any) int64 { return int64(len(value.([]string))) * 16 },
+			}
+			if err := ctx.CreateType(stack); err != nil {
+				return err
+			}
+			err := ctx.CreateCommand(command.Spec{Name: "stack.keep", Arity: 2}, func(ks module.Keyspace, args [][]byte) protocol.RedisMessage {
+				kept = append(kept, args[0])
+				return &protocol.RedisOk
+			})
+			if err != nil {
+				return err
+			}
+			return ctx.CreateCommand(command.Spec{
+				Name: "stack.push", Arity: 3, Flags: command.FlagWrite,
+				Keys: []command.KeySpec{{Flags: command.KeyRW | command.KeyInsert, Index: 1}},
+			}, func(ks module.Keyspace, args [][]byte) protocol.RedisMessage {
+				data, err := stack.Get(ks, string(args[0]))
+				if err != nil {
+					return err
+				}
+				items, _ := data.([]string)
+				items = append(items, string(args[1]))
+				stack.Set(ks, string(args[0]), items)
+				return protocol.MakeInteger(int64(len(items)))
+			})
+		}}
+		s, err := New(WithModule(m))
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		reply, err := s.Do(ctx, "stack.push", "s", "a")
+		So(err, ShouldBeNil)
+		So(string(reply.Bytes()), ShouldEqual, ":1\r\n")
+		reply, _ = s.Do(ctx, "stack.push", "s", "b")
+		So(string(reply.Bytes()), ShouldEqual, ":2\r\n")
+		reply, _ = s.Do(ctx, "object", "encoding", "s")
+		So(string(reply.Bytes()), ShouldEqual, "$3\r\nraw\r\n")
+		reply, _ = s.Do(ctx, "memory", "usage", "s")
+		So(string(reply.Bytes()), ShouldEqual, ":97\r\n")
+		reply, _ = s.Do(ctx, "type", "s")
+		So(string(reply.Bytes()), ShouldEqual, "+stack-typ\r\n")
+		_, err = s.Do(ctx, "copy", "s", "c")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "ERR not supported for this module key")
+		reply, _ = s.Do(ctx, "rename", "s", "t")
+		So(string(reply.Bytes()), ShouldEqual, "+OK\r\n")
+
+		s.Do(ctx, "set", "str", "v")
+		_, err = s.Do(ctx, "stack.push", "str", "a")
+		So(err, ShouldEqual, &protocol.WrongTypeError)
+
+		client, conn := net.Pipe()
+		go s.ServeConn(conn)
+		r := bufio.NewReader(client)
+		So(request(client, r, "*2\r\n$10\r\nstack.keep\r\n$4\r\nkey1\r\n"), ShouldEqual, "+OK\r\n")
+		So(request(client, r, "*2\r\n$10\r\nstack.keep\r\n$4\r\nkey2\r\n"), ShouldEqual, "+OK\r\n")
+		client.Close()
+		So(string(kept[0]), ShouldEqual, "key1")
+
+		reply, _ = s.Do(ctx, "command", "list", "filterby", "module", "stack")
+		So(string(reply.Bytes()), ShouldEqual, "*2\r\n$10\r\nstack.keep\r\n$10\r\nstack.push\r\n")
+		reply, _ = s.Do(ctx, "module", "list")
+		So(string(reply.Bytes()), ShouldEqual, "*1\r\n*8\r\n$4\r\nname\r\n$5\r\nstack\r\n$3\r\nver\r\n:1\r\n$4\r\npath\r\n$0\r\n\r\n$4\r\nargs\r\n*0\r\n")
+
+		So(s.Close(), ShouldBeNil)
+
+		_, err = New(WithModule(&module.Module{Name: "stack", OnLoad: m.OnLoad}))
+		So(err, ShouldNotBeNil)
+
+		// a server loaded without the module doesn't know its commands
+		other, err := New()
+		So(err, ShouldBeNil)
+		defer other.Close()
+		_, err = other.Do(ctx, "stack.push", "s", "a")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "ERR unknown command `stack.push`")
+		reply, _ = other.Do(ctx, "command", "info", "stack.push")
+		So(string(reply.Bytes()), ShouldEqual, "*1\r\n$-1\r\n")
+		reply, _ = other.Do(ctx, "module", "list")
+		So(string(reply.Bytes()), ShouldEqual, "*0\r\n")
+	})
+}
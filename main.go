@@ -1,17 +1,34 @@
 package main
 
 import (
+	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/core/command"
 	"github.com/HwHgoo/Gredis/tcpserver"
 )
 
 func main() {
 	// defer profile.Start(profile.ProfilePath(".")).Stop()
-	s := tcpserver.MakeTcpServer()
+	if err := config.LoadFromArgs(os.Args[1:]); err != nil {
+		log.Fatalln("*** FATAL CONFIG FILE ERROR ***", err)
+	}
+	s, err := tcpserver.MakeTcpServer()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, rename := range config.Properties().RenamedCommands {
+		if err := command.Rename(rename[0], rename[1]); err != nil {
+			log.Fatalln("*** FATAL CONFIG FILE ERROR ***", err)
+		}
+	}
+
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	s.ListenAndServe(signals)
+	if err := s.ListenAndServe(signals); err != nil {
+		log.Fatalln(err)
+	}
 }
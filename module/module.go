@@ -0,0 +1,157 @@
+// Package module extends Gredis with commands and data types written in
+// Go, like Redis modules. A module is registered, typically from the init
+// function of its package, and loaded when a server is constructed.
+//
+//	func init() {
+//		module.Register(&module.Module{Name: "ratelimit", Version: 1, OnLoad: onLoad})
+//	}
+package module
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+type Module struct {
+	Name    string
+	Version int
+	// OnLoad creates the commands and types of the module. If it fails,
+	// what it created is removed and the server isn't constructed.
+	OnLoad func(ctx *Context) error
+}
+
+var (
+	lock sync.Mutex
+	// registered modules in registration order
+	registered []*Module
+	// loaded modules by name
+	loaded = make(map[string]*Module)
+)
+
+// Register adds a module to be loaded when the server is constructed
+func Register(m *Module) {
+	lock.Lock()
+	defer lock.Unlock()
+	registered = append(registered, m)
+}
+
+// Registered returns the modules registered with Register, every
+// server loads them along the modules it is constructed with
+func Registered() []*Module {
+	lock.Lock()
+	defer lock.Unlock()
+	return slices.Clone(registered)
+}
+
+// Load loads the modules not loaded yet. Commands are shared by every
+// server of the process, so a module is only loaded once: servers only
+// execute the commands of the modules they were constructed with. A
+// registered module failing to load is unregistered.
+func Load(modules ...*Module) error {
+	lock.Lock()
+	defer lock.Unlock()
+	for _, m := range modules {
+		if loaded[m.Name] == m {
+			continue
+		}
+		if err := load(m); err != nil {
+			registered = slices.DeleteFunc(registered, func(r *Module) bool { return r == m })
+			return errors.New("Error loading the extension " + m.Name + ": " + err.Error())
+		}
+		loaded[m.Name] = m
+	}
+	return nil
+}
+
+func load(m *Module) error {
+	if m.Name == "" || m.OnLoad == nil {
+		return errors.New("a module needs a name and an OnLoad function")
+	}
+	if loaded[m.Name] != nil {
+		return errors.New("a module with the same name is already loaded")
+	}
+
+	ctx := &Context{module: m}
+	if err := m.OnLoad(ctx); err != nil {
+		ctx.unload()
+		return err
+	}
+	return nil
+}
+
+// Keyspace is the database a command of a module is executed on
+type Keyspace interface {
+	Get(key string) (value any, ok bool)
+	Set(key string, value any)
+	Delete(key string) int
+}
+
+// CommandFunc executes a command of a module with the arguments following
+// the command name, e.g. the key and the value of SET. The arguments are
+// copied from the parser's buffers, so they may be kept past the call.
+type CommandFunc func(ks Keyspace, args [][]byte) protocol.RedisMessage
+
+// Context creates the commands and types of a module while it is loaded
+type Context struct {
+	module *Module
+	// created so far, removed if the module fails to load
+	commands []string
+	types    []string
+}
+
+// CreateCommand adds a command. The name must not be taken, the
+// other fields of the spec describe the command as for built-in ones.
+// exec may keep its arguments, see CommandFunc.
+func (ctx *Context) CreateCommand(spec command.Spec, exec CommandFunc) error {
+	spec.Name = strings.ToLower(spec.Name)
+	if spec.Name == "" || strings.Contains(spec.Name, "|") {
+		return errors.New("invalid command name '" + spec.Name + "'")
+	}
+	if command.Exists(spec.Name) {
+		return errors.New("command '" + spec.Name + "' already exists")
+	}
+
+	command.Register(ctx.describe(spec), command.DatabaseExecutor(exec))
+	ctx.commands = append(ctx.commands, spec.Name)
+	return nil
+}
+
+// CreateSubcommand adds a subcommand to a command created by the module.
+// Its arity counts both the command and subcommand names.
+func (ctx *Context) CreateSubcommand(parent string, spec command.Spec, exec CommandFunc) error {
+	parent, spec.Name = strings.ToLower(parent), strings.ToLower(spec.Name)
+	p := command.Lookup(parent)
+	if p == nil || p.Module != ctx.module.Name {
+		return errors.New("command '" + parent + "' isn't a command of the module")
+	}
+	if spec.Name == "" || strings.Contains(spec.Name, "|") || p.Subcommand(spec.Name) != nil {
+		return errors.New("invalid or existing subcommand name '" + spec.Name + "'")
+	}
+
+	command.RegisterSubcommand(parent, ctx.describe(spec), command.DatabaseExecutor(exec))
+	return nil
+}
+
+func (ctx *Context) describe(spec command.Spec) command.Spec {
+	spec.Module = ctx.module.Name
+	if spec.Group == "" {
+		spec.Group = "module"
+	}
+	return spec
+}
+
+func (ctx *Context) unload() {
+	for _, name := range ctx.commands {
+		_ = command.Rename(name, "")
+	}
+	typesLock.Lock()
+	defer typesLock.Unlock()
+	for _, name := range ctx.types {
+		delete(types, name)
+	}
+}
@@ -0,0 +1,134 @@
+package module
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/interface/redis"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type keyspace map[string]any
+
+func (ks keyspace) Get(key string) (any, bool) { v, ok := ks[key]; return v, ok }
+
+func (ks keyspace) Set(key string, value any) { ks[key] = value }
+
+func (ks keyspace) Delete(key string) int {
+	if _, ok := ks[key]; !ok {
+		return 0
+	}
+	delete(ks, key)
+	return 1
+}
+
+// Exec makes keyspace a redis.DB, commands are executed through the registry
+func (ks keyspace) Exec(redis.Connection, [][]byte) protocol.RedisMessage { return nil }
+
+func exec(ks keyspace, args ...string) protocol.RedisMessage {
+	argv := make([][]byte, len(args))
+	for i, arg := range args {
+		argv[i] = []byte(arg)
+	}
+	spec, err := command.Find(argv)
+	So(err, ShouldBeNil)
+	return command.ExecDatabaseCommand(spec, ks, argv)
+}
+
+func counterType() *Type {
+	return &Type{
+		Name:    "counter-t",
+		RDBSave: func(w io.Writer, value any) error { return nil },
+		RDBLoad: func(r io.Reader, encodingVersion int) (any, error) { return 0, nil },
+	}
+}
+
+func TestLoad(t *testing.T) {
+	Convey("TestLoad", t, func() {
+		Convey("commands and types are created", func() {
+			var counter *Type
+			m := &Module{Name: "counter", Version: 2, OnLoad: func(ctx *Context) error {
+				counter = counterType()
+				if err := ctx.CreateType(counter); err != nil {
+					return err
+				}
+				return ctx.CreateCommand(command.Spec{Name: "INCRCOUNTER", Arity: 2}, func(ks Keyspace, args [][]byte) protocol.RedisMessage {
+					n, err := counter.Get(ks, string(args[0]))
+					if err != nil {
+						return err
+					}
+					c, _ := n.(int)
+					counter.Set(ks, string(args[0]), c+1)
+					return protocol.MakeInteger(int64(c + 1))
+				})
+			}}
+			So(Load(m), ShouldBeNil)
+			So(Load(m), ShouldBeNil)
+			So(LookupType("counter-t"), ShouldEqual, counter)
+
+			spec := command.Lookup("incrcounter")
+			So(spec, ShouldNotBeNil)
+			So(spec.Module, ShouldEqual, "counter")
+			So(spec.Group, ShouldEqual, "module")
+
+			ks := keyspace{"str": []byte("v")}
+			So(exec(ks, "incrcounter", "k"), ShouldResemble, protocol.MakeInteger(1))
+			So(exec(ks, "incrcounter", "k"), ShouldResemble, protocol.MakeInteger(2))
+			So(exec(ks, "incrcounter", "str"), ShouldEqual, &protocol.WrongTypeError)
+
+			So(Load(&Module{Name: "counter", OnLoad: func(ctx *Context) error { return nil }}), ShouldNotBeNil)
+		})
+
+		Convey("a failed load removes what was created", func() {
+			broken := &Module{Name: "broken", OnLoad: func(ctx *Context) error {
+				if err := ctx.CreateCommand(command.Spec{Name: "broken.cmd", Arity: 1}, nil); err != nil {
+					return err
+				}
+				t := counterType()
+				t.Name = "broken-tt"
+				if err := ctx.CreateType(t); err != nil {
+					return err
+				}
+				return errors.New("no luck")
+			}}
+			Register(broken)
+			So(Registered(), ShouldContain, broken)
+			err := Load(Registered()...)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "Error loading the extension broken: no luck")
+			So(command.Exists("broken.cmd"), ShouldBeFalse)
+			So(LookupType("broken-tt"), ShouldBeNil)
+			So(Registered(), ShouldNotContain, broken)
+		})
+	})
+}
+
+func TestCreate(t *testing.T) {
+	Convey("TestCreate", t, func() {
+		ctx := &Context{module: &Module{Name: "creator"}}
+		defer ctx.unload()
+
+		So(ctx.CreateCommand(command.Spec{Name: "a|b"}, nil), ShouldNotBeNil)
+		So(ctx.CreateCommand(command.Spec{Name: ""}, nil), ShouldNotBeNil)
+		So(ctx.CreateCommand(command.Spec{Name: "creator.tool", Arity: -2}, nil), ShouldBeNil)
+		So(ctx.CreateCommand(command.Spec{Name: "CREATOR.TOOL", Arity: -2}, nil), ShouldNotBeNil)
+
+		other := &Context{module: &Module{Name: "other"}}
+		So(other.CreateSubcommand("creator.tool", command.Spec{Name: "mine", Arity: 2}, nil), ShouldNotBeNil)
+		So(other.CreateSubcommand("missing", command.Spec{Name: "mine", Arity: 2}, nil), ShouldNotBeNil)
+		So(ctx.CreateSubcommand("creator.tool", command.Spec{Name: "run", Arity: 2}, nil), ShouldBeNil)
+		So(ctx.CreateSubcommand("creator.tool", command.Spec{Name: "run", Arity: 2}, nil), ShouldNotBeNil)
+
+		t := counterType()
+		t.Name = "short"
+		So(ctx.CreateType(t), ShouldNotBeNil)
+		t.Name = "bad name!"
+		So(ctx.CreateType(t), ShouldNotBeNil)
+		t.Name, t.RDBSave, t.RDBLoad = "creator-t", nil, nil
+		So(ctx.CreateType(t), ShouldBeNil)
+		So(ctx.CreateType(t), ShouldNotBeNil)
+	})
+}
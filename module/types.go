@@ -0,0 +1,99 @@
+package module
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/HwHgoo/Gredis/core/protocol"
+)
+
+// length of the name of a type, like for Redis module types
+const type_name_length = 9
+
+// Type is a data type created by a module. Its values are stored
+// in the databases as *Value.
+type Type struct {
+	// name reported by TYPE, made of 9 characters among A-Z, a-z, 0-9, - and _
+	Name string
+	// version of the serialized format, passed back to RDBLoad
+	EncodingVersion int
+
+	// RDBSave writes a value to an RDB file and RDBLoad reads it back,
+	// AOFRewrite returns the commands rebuilding the value of key. There is
+	// no persistence yet: these hooks are optional and never called.
+	RDBSave    func(w io.Writer, value any) error
+	RDBLoad    func(r io.Reader, encodingVersion int) (any, error)
+	AOFRewrite func(key string, value any) [][][]byte
+	// MemoryUsage returns the size of a value in bytes reported by
+	// MEMORY USAGE, optional
+	MemoryUsage func(value any) int64
+	// Copy returns a deep copy of a value for COPY, optional,
+	// COPY fails without it
+	Copy func(value any) any
+}
+
+// Value is a value of a module type
+type Value struct {
+	Type *Type
+	Data any
+}
+
+var (
+	typesLock sync.Mutex
+	types     = make(map[string]*Type)
+)
+
+// CreateType adds a data type, its name must not be taken
+func (ctx *Context) CreateType(t *Type) error {
+	if !validTypeName(t.Name) {
+		return errors.New("invalid type name '" + t.Name + "', 9 characters among A-Z, a-z, 0-9, - and _ are expected")
+	}
+
+	typesLock.Lock()
+	defer typesLock.Unlock()
+	if types[t.Name] != nil {
+		return errors.New("type '" + t.Name + "' already exists")
+	}
+	types[t.Name] = t
+	ctx.types = append(ctx.types, t.Name)
+	return nil
+}
+
+func validTypeName(name string) bool {
+	if len(name) != type_name_length {
+		return false
+	}
+	for _, c := range []byte(name) {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// LookupType returns the type created with the name, or nil
+func LookupType(name string) *Type {
+	typesLock.Lock()
+	defer typesLock.Unlock()
+	return types[name]
+}
+
+// Get returns the data of the value of key, nil if the key doesn't
+// exist or a WRONGTYPE error if its value isn't of the type
+func (t *Type) Get(ks Keyspace, key string) (any, protocol.RedisErrorMessage) {
+	value, ok := ks.Get(key)
+	if !ok {
+		return nil, nil
+	}
+	v, ok := value.(*Value)
+	if !ok || v.Type != t {
+		return nil, &protocol.WrongTypeError
+	}
+	return v.Data, nil
+}
+
+// Set sets the value of key to data of the type
+func (t *Type) Set(ks Keyspace, key string, data any) {
+	ks.Set(key, &Value{Type: t, Data: data})
+}
@@ -0,0 +1,240 @@
+package tcpserver
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// command encodes args as a RESP array of bulk strings
+func command(args ...string) string {
+	b := strings.Builder{}
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	return b.String()
+}
+
+func TestClientCommand(t *testing.T) {
+	Convey("TestClientCommand", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+		_, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+		other := dial("tcp", addr)
+		defer other.Close()
+		So(request(other, command("select", "2")), ShouldEqual, "+OK\r\n")
+
+		id := request(conn, command("client", "id"))
+		So(id, ShouldStartWith, ":")
+		id = strings.TrimSpace(id[1:])
+		otherId := strings.TrimSpace(request(other, command("client", "id"))[1:])
+
+		Convey("names", func() {
+			So(request(conn, command("client", "getname")), ShouldEqual, "$-1\r\n")
+			So(request(conn, command("client", "setname", "worker-1")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("client", "getname")), ShouldEqual, "$8\r\nworker-1\r\n")
+			So(request(conn, command("client", "setname", "a b")), ShouldStartWith, "-ERR Client names cannot contain spaces")
+		})
+
+		Convey("info and list", func() {
+			So(request(conn, command("client", "setinfo", "lib-name", "redis-py")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("client", "setinfo", "lib-foo", "x")), ShouldStartWith, "-ERR Unrecognized option")
+
+			info := request(conn, command("client", "info"))
+			So(info, ShouldContainSubstring, "id="+id+" addr="+conn.LocalAddr().String()+" laddr="+addr)
+			So(info, ShouldContainSubstring, " db=0 ")
+			So(info, ShouldContainSubstring, " cmd=client|info ")
+			So(info, ShouldContainSubstring, " lib-name=redis-py ")
+
+			list := request(conn, command("client", "list"))
+			So(strings.Count(list, "id="), ShouldEqual, 2)
+			So(list, ShouldContainSubstring, "id="+otherId+" ")
+			So(list, ShouldContainSubstring, " db=2 qbuf=0 omem=0 cmd=client|id ")
+
+			list = request(conn, command("client", "list", "id", otherId))
+			So(strings.Count(list, "id="), ShouldEqual, 1)
+			So(list, ShouldContainSubstring, "id="+otherId+" ")
+			So(request(conn, command("client", "list", "type", "foo")), ShouldStartWith, "-ERR Unknown client type")
+		})
+
+		Convey("kill by id and address", func() {
+			So(request(conn, command("client", "kill", "id", id)), ShouldEqual, ":0\r\n")
+			So(request(conn, command("client", "kill", "user", "bob")), ShouldStartWith, "-ERR No such user")
+			So(request(conn, command("client", "kill", "id", otherId)), ShouldEqual, ":1\r\n")
+			other.SetReadDeadline(time.Now().Add(time.Second))
+			_, err := other.Read(make([]byte, 16))
+			So(err, ShouldEqual, io.EOF)
+
+			So(request(conn, command("client", "kill", "127.0.0.1:1")), ShouldEqual, "-ERR No such client\r\n")
+			So(request(conn, command("client", "kill", conn.LocalAddr().String())), ShouldEqual, "+OK\r\n")
+			_, err = conn.Read(make([]byte, 16))
+			So(err, ShouldEqual, io.EOF)
+		})
+
+		Convey("kill itself", func() {
+			So(request(conn, command("client", "kill", "laddr", addr, "skipme", "no")), ShouldEqual, ":2\r\n")
+			_, err := conn.Read(make([]byte, 16))
+			So(err, ShouldEqual, io.EOF)
+		})
+
+		Convey("unknown subcommand", func() {
+			So(request(conn, command("client", "foo")), ShouldEqual, "-ERR unknown subcommand 'foo'. Try CLIENT HELP.\r\n")
+			So(request(conn, command("client", "id", "1")), ShouldStartWith, "-ERR wrong number of arguments")
+		})
+	})
+}
+
+func TestClientPause(t *testing.T) {
+	Convey("TestClientPause", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+		_, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+		admin := dial("tcp", addr)
+		defer admin.Close()
+
+		Convey("write pause holds writes until unpaused", func() {
+			So(request(admin, command("client", "pause", "10000", "write")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("get", "k")), ShouldEqual, "$-1\r\n")
+
+			replied := make(chan string, 1)
+			go func() {
+				conn.Write([]byte(command("set", "k", "v")))
+				buf := make([]byte, 16)
+				n, _ := conn.Read(buf)
+				replied <- string(buf[:n])
+			}()
+			select {
+			case <-replied:
+				t.Error("write command should be held")
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			So(request(admin, command("client", "unpause")), ShouldEqual, "+OK\r\n")
+			So(<-replied, ShouldEqual, "+OK\r\n")
+		})
+
+		Convey("pause ends after its timeout", func() {
+			So(request(admin, command("client", "pause", "200")), ShouldEqual, "+OK\r\n")
+			start := time.Now()
+			So(request(conn, command("get", "k")), ShouldEqual, "$-1\r\n")
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 150*time.Millisecond)
+		})
+
+		Convey("bad arguments", func() {
+			So(request(admin, command("client", "pause", "abc")), ShouldStartWith, "-ERR timeout is not an integer")
+			So(request(admin, command("client", "pause", "-1")), ShouldEqual, "-ERR timeout is negative\r\n")
+			So(request(admin, command("client", "pause", "10", "read")), ShouldEqual, "-ERR syntax error\r\n")
+		})
+	})
+}
+
+func TestClientReply(t *testing.T) {
+	Convey("TestClientReply", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+		_, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+
+		Convey("replies off until turned on again", func() {
+			So(request(conn, command("client", "reply", "off")+command("set", "a", "1")+command("foo")+
+				command("client", "reply", "on")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("get", "a")), ShouldEqual, "$1\r\n1\r\n")
+		})
+
+		Convey("skip the reply to the next command only", func() {
+			So(request(conn, command("client", "reply", "skip")+command("set", "b", "2")+command("get", "b")), ShouldEqual, "$1\r\n2\r\n")
+		})
+
+		Convey("no-evict and no-touch are shown in the flags", func() {
+			So(request(conn, command("client", "no-evict", "on")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("client", "no-touch", "on")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("client", "info")), ShouldContainSubstring, " flags=eT ")
+			So(request(conn, command("client", "no-touch", "off")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("client", "info")), ShouldContainSubstring, " flags=e ")
+			So(request(conn, command("client", "reply", "maybe")), ShouldEqual, "-ERR syntax error\r\n")
+		})
+	})
+}
+
+// hello sends HELLO and reads the whole reply, which ends with the modules
+func hello(conn net.Conn, args ...string) string {
+	_, err := conn.Write([]byte(command(append([]string{"hello"}, args...)...)))
+	So(err, ShouldBeNil)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	reply := ""
+	buf := make([]byte, 512)
+	for !strings.HasSuffix(reply, "$7\r\nmodules\r\n*0\r\n") && !strings.HasPrefix(reply, "-") {
+		n, err := conn.Read(buf)
+		So(err, ShouldBeNil)
+		reply += string(buf[:n])
+	}
+	return reply
+}
+
+func TestHello(t *testing.T) {
+	Convey("TestHello", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+		_, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+
+		Convey("RESP2 by default", func() {
+			So(hello(conn), ShouldStartWith, "*14\r\n$6\r\nserver\r\n$5\r\nredis\r\n")
+			So(request(conn, command("get", "nokey")), ShouldEqual, "$-1\r\n")
+		})
+
+		Convey("switch to RESP3 and back", func() {
+			reply := hello(conn, "3", "auth", "default", "secret", "setname", "loader")
+			So(reply, ShouldStartWith, "%7\r\n")
+			So(reply, ShouldContainSubstring, "$5\r\nproto\r\n:3\r\n")
+			So(request(conn, command("get", "nokey")), ShouldEqual, "_\r\n")
+			So(request(conn, command("zadd", "zs", "1.5", "a")), ShouldEqual, ":1\r\n")
+			So(request(conn, command("zscore", "zs", "a")), ShouldEqual, ",1.5\r\n")
+			So(request(conn, command("client", "info")), ShouldContainSubstring, " name=loader ")
+			So(request(conn, command("client", "info")), ShouldContainSubstring, " resp=3 ")
+
+			So(hello(conn, "2"), ShouldContainSubstring, "$5\r\nproto\r\n:2\r\n")
+			So(request(conn, command("get", "nokey")), ShouldEqual, "$-1\r\n")
+			So(request(conn, command("zscore", "zs", "a")), ShouldEqual, "$3\r\n1.5\r\n")
+		})
+
+		Convey("bad arguments", func() {
+			So(request(conn, command("hello", "4")), ShouldEqual, "-NOPROTO unsupported protocol version\r\n")
+			So(request(conn, command("hello", "x")), ShouldStartWith, "-ERR Protocol version is not an integer")
+			So(request(conn, command("hello", "3", "auth", "bob", "pass")), ShouldStartWith, "-WRONGPASS")
+			So(request(conn, command("hello", "3", "foo")), ShouldEqual, "-ERR Syntax error in HELLO option 'foo'\r\n")
+			// failed negotiation keeps the protocol
+			So(request(conn, command("get", "nokey")), ShouldEqual, "$-1\r\n")
+		})
+	})
+}
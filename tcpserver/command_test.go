@@ -0,0 +1,208 @@
+package tcpserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/connection"
+	registry "github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/module"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// readReply reads a whole reply, including the elements of aggregates
+func readReply(r *bufio.Reader) string {
+	line, err := r.ReadString('\n')
+	So(err, ShouldBeNil)
+	n, _ := strconv.Atoi(line[1 : len(line)-2])
+	switch line[0] {
+	case '$', '=', '!':
+		if n >= 0 {
+			bulk := make([]byte, n+2)
+			_, err = io.ReadFull(r, bulk)
+			So(err, ShouldBeNil)
+			line += string(bulk)
+		}
+	case '%', '|':
+		n *= 2
+		fallthrough
+	case '*', '~', '>':
+		for i := 0; i < n; i++ {
+			line += readReply(r)
+		}
+	}
+	return line
+}
+
+func requestAll(conn net.Conn, req string) string {
+	_, err := conn.Write([]byte(req))
+	So(err, ShouldBeNil)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	return readReply(bufio.NewReader(conn))
+}
+
+func TestCommandIntrospection(t *testing.T) {
+	Convey("TestCommandIntrospection", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+		_, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+
+		Convey("count and list", func() {
+			count := request(conn, command("command", "count"))
+			So(count, ShouldStartWith, ":")
+			n, _ := strconv.Atoi(count[1 : len(count)-2])
+			// subcommands are listed along commands
+			list := requestAll(conn, command("command", "list"))
+			So(strings.Count(list, "$")-strings.Count(list, "|"), ShouldEqual, n)
+			So(list, ShouldContainSubstring, "\r\nclient|list\r\n")
+
+			So(requestAll(conn, command("command", "list", "filterby", "pattern", "z*")), ShouldEqual,
+				"*4\r\n$4\r\nzadd\r\n$5\r\nzcard\r\n$6\r\nzcount\r\n$6\r\nzscore\r\n")
+			So(requestAll(conn, command("command", "list", "filterby", "aclcat", "SortedSet")), ShouldStartWith, "*4\r\n")
+			So(requestAll(conn, command("command", "list", "filterby", "module", "foo")), ShouldEqual, "*0\r\n")
+			So(requestAll(conn, command("command", "list", "filterby", "pattern", "config|*")), ShouldEqual,
+				"*5\r\n$10\r\nconfig|get\r\n$11\r\nconfig|help\r\n$16\r\nconfig|resetstat\r\n$14\r\nconfig|rewrite\r\n$10\r\nconfig|set\r\n")
+			So(request(conn, command("command", "list", "filterby", "foo", "bar")), ShouldEqual, "-ERR syntax error\r\n")
+		})
+
+		Convey("info", func() {
+			So(requestAll(conn, command("command", "info", "get", "nosuch")), ShouldEqual, "*2\r\n"+
+				"*10\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n*3\r\n+@read\r\n+@string\r\n+@fast\r\n*0\r\n"+
+				"*1\r\n*6\r\n$5\r\nflags\r\n*2\r\n+RO\r\n+access\r\n"+
+				"$12\r\nbegin_search\r\n*4\r\n$4\r\ntype\r\n$5\r\nindex\r\n$4\r\nspec\r\n*2\r\n$5\r\nindex\r\n:1\r\n"+
+				"$9\r\nfind_keys\r\n*4\r\n$4\r\ntype\r\n$5\r\nrange\r\n$4\r\nspec\r\n*6\r\n$7\r\nlastkey\r\n:0\r\n$7\r\nkeystep\r\n:1\r\n$5\r\nlimit\r\n:0\r\n"+
+				"*0\r\n"+
+				"$-1\r\n")
+
+			info := requestAll(conn, command("command", "info", "config"))
+			So(info, ShouldStartWith, "*1\r\n*10\r\n$6\r\nconfig\r\n:-2\r\n")
+			So(info, ShouldContainSubstring, "$10\r\nconfig|set\r\n:-4\r\n*4\r\n+admin\r\n+noscript\r\n+loading\r\n+stale\r\n")
+			So(requestAll(conn, command("command", "info", "client|id")), ShouldStartWith, "*1\r\n*10\r\n$9\r\nclient|id\r\n:2\r\n")
+
+			info = requestAll(conn, command("command", "info", "mset"))
+			So(info, ShouldContainSubstring, ":1\r\n:-1\r\n:2\r\n")
+			So(info, ShouldContainSubstring, "+denyoom\r\n")
+		})
+
+		Convey("docs", func() {
+			So(requestAll(conn, command("command", "docs", "strlen", "nosuch")), ShouldEqual, "*2\r\n$6\r\nstrlen\r\n*8\r\n"+
+				"$7\r\nsummary\r\n$37\r\nReturns the length of a string value.\r\n$5\r\nsince\r\n$5\r\n2.2.0\r\n"+
+				"$5\r\ngroup\r\n$6\r\nstring\r\n$10\r\ncomplexity\r\n$4\r\nO(1)\r\n")
+		})
+
+		Convey("docs of subcommands", func() {
+			docs := requestAll(conn, command("command", "docs", "client"))
+			So(docs, ShouldContainSubstring, "$11\r\nsubcommands\r\n")
+			So(docs, ShouldContainSubstring, "$14\r\nclient|setname\r\n*8\r\n$7\r\nsummary\r\n$25\r\nSets the connection name.\r\n")
+		})
+
+		Convey("getkeys", func() {
+			So(requestAll(conn, command("command", "getkeys", "mset", "a", "1", "b", "2")), ShouldEqual, "*2\r\n$1\r\na\r\n$1\r\nb\r\n")
+			So(requestAll(conn, command("command", "getkeys", "lcs", "x", "y", "len")), ShouldEqual, "*2\r\n$1\r\nx\r\n$1\r\ny\r\n")
+			So(request(conn, command("command", "getkeys", "ping")), ShouldEqual, "-ERR The command has no key arguments\r\n")
+			So(request(conn, command("command", "getkeys", "get")), ShouldEqual, "-ERR Invalid number of arguments specified for command\r\n")
+			So(request(conn, command("command", "getkeys", "nosuch")), ShouldEqual, "-ERR Invalid command specified\r\n")
+		})
+
+		Convey("subcommand dispatch", func() {
+			So(request(conn, command("command", "foo")), ShouldEqual, "-ERR unknown subcommand 'foo'. Try COMMAND HELP.\r\n")
+			So(request(conn, command("command", "count", "1")), ShouldEqual, "-ERR wrong number of arguments for 'command|count' command\r\n")
+			So(request(conn, command("config")), ShouldEqual, "-ERR wrong number of arguments for 'config' command\r\n")
+			So(request(conn, command("config", "set", "timeout")), ShouldEqual, "-ERR wrong number of arguments for 'config|set' command\r\n")
+			So(request(conn, command("config", "set", "timeout", "0", "port")), ShouldEqual, "-ERR wrong number of arguments for 'config|set' command\r\n")
+			So(request(conn, command("client", "pause", "10", "all", "x")), ShouldEqual, "-ERR syntax error\r\n")
+		})
+
+		Convey("renamed command", func() {
+			So(registry.Rename("config", "cfg"), ShouldBeNil)
+			defer registry.Rename("cfg", "config")
+
+			So(request(conn, command("config", "get", "port")), ShouldEqual, "-ERR unknown command `config`, with args beginning with: `g`\r\n")
+			So(requestAll(conn, command("cfg", "get", "databases")), ShouldEqual, "*2\r\n$9\r\ndatabases\r\n$2\r\n16\r\n")
+			So(requestAll(conn, command("command", "list", "filterby", "pattern", "config*")), ShouldEqual, "*0\r\n")
+			So(requestAll(conn, command("command", "list", "filterby", "pattern", "cfg|get")), ShouldEqual, "*1\r\n$7\r\ncfg|get\r\n")
+			So(requestAll(conn, command("command", "info", "config")), ShouldEqual, "*1\r\n$-1\r\n")
+			So(requestAll(conn, command("command", "info", "cfg")), ShouldStartWith, "*1\r\n*10\r\n$3\r\ncfg\r\n")
+			So(requestAll(conn, command("cfg", "help")), ShouldStartWith, "*11\r\n+CFG <subcommand>")
+		})
+
+		Convey("help", func() {
+			So(requestAll(conn, command("config", "help")), ShouldEqual, "*11\r\n"+
+				"+CONFIG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:\r\n"+
+				"+GET <pattern>\r\n+    Return parameters matching the glob-like <pattern> and their values.\r\n"+
+				"+SET <directive> <value>\r\n+    Set the configuration <directive> to <value>.\r\n"+
+				"+RESETSTAT\r\n+    Reset statistics reported by the INFO command.\r\n"+
+				"+REWRITE\r\n+    Rewrite the configuration file.\r\n"+
+				"+HELP\r\n+    Print this help.\r\n")
+
+			help := requestAll(conn, command("command", "help"))
+			So(help, ShouldContainSubstring, "+COMMAND <subcommand> [<arg> [value] [opt] ...]. Subcommands are:\r\n+(no subcommand)\r\n")
+			So(help, ShouldEndWith, "+HELP\r\n+    Print this help.\r\n")
+			So(requestAll(conn, command("client", "help")), ShouldContainSubstring, "+SETINFO <option> <value>\r\n")
+		})
+	})
+}
+
+func TestLoadModuleWhileServing(t *testing.T) {
+	Convey("modules are loaded while another server executes commands", t, func() {
+		running := makeTcpServer().Redis()
+		// receives once per batch of commands executed, until stop is closed.
+		// The commands don't go through a socket, which would order them
+		// with the loading for the race detector.
+		rounds, stop := make(chan struct{}), make(chan struct{})
+		go func() {
+			c := connection.MakeFakeConnection()
+			for {
+				running.Exec(c, [][]byte{[]byte("command"), []byte("count")})
+				running.Exec(c, [][]byte{[]byte("client"), []byte("id")})
+				running.Exec(c, [][]byte{[]byte("config"), []byte("resetstat")})
+				select {
+				case rounds <- struct{}{}:
+				case <-stop:
+					return
+				}
+			}
+		}()
+		defer close(stop)
+
+		for i := 0; i < 20; i++ {
+			<-rounds
+			name := "loading" + strconv.Itoa(i)
+			m := &module.Module{Name: name, OnLoad: func(ctx *module.Context) error {
+				err := ctx.CreateCommand(registry.Spec{Name: name + ".cmd", Arity: -2}, nil)
+				if err != nil {
+					return err
+				}
+				err = ctx.CreateSubcommand(name+".cmd", registry.Spec{Name: "run", Arity: 2},
+					func(ks module.Keyspace, args [][]byte) protocol.RedisMessage { return &protocol.RedisOk })
+				if err != nil || i%2 == 0 {
+					return err
+				}
+				// a failed load removes the command again
+				return io.ErrUnexpectedEOF
+			}}
+			s, err := MakeTcpServer(m)
+			So(err == nil, ShouldEqual, i%2 == 0)
+			if s != nil {
+				So(s.Redis().Exec(connection.MakeFakeConnection(), [][]byte{[]byte(name + ".cmd"), []byte("run")}), ShouldEqual, &protocol.RedisOk)
+			}
+		}
+		// the running server didn't load the modules
+		reply := running.Exec(connection.MakeFakeConnection(), [][]byte{[]byte("loading0.cmd"), []byte("run")})
+		So(string(reply.Bytes()), ShouldStartWith, "-ERR unknown command `loading0.cmd`")
+	})
+}
@@ -2,31 +2,39 @@ package tcpserver
 
 import (
 	"context"
-	"io"
+	"crypto/tls"
+	"errors"
 	"log"
 	"net"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/HwHgoo/Gredis/config"
 	"github.com/HwHgoo/Gredis/connection"
 	"github.com/HwHgoo/Gredis/core/parser"
 	"github.com/HwHgoo/Gredis/core/protocol"
 	"github.com/HwHgoo/Gredis/core/server"
 )
 
+const reject_write_timeout = time.Second
+
 type Handler struct {
 	closing atomic.Bool
+	closed  atomic.Bool
 
-	connections map[*connection.Connection]struct{}
-	conn_lock   sync.RWMutex
+	// number of commands being executed or having their replies written
+	inflight atomic.Int64
+	// closed when draining ends, nil while not draining
+	drain     chan struct{}
+	drainLock sync.Mutex
 
 	redis *server.Server
 }
 
 func MakeHandler(redis_server *server.Server) *Handler {
 	return &Handler{
-		connections: make(map[*connection.Connection]struct{}),
-		redis:       redis_server,
+		redis: redis_server,
 	}
 }
 
@@ -37,39 +45,196 @@ func (h *Handler) Handle(ctx context.Context, conn net.Conn) {
 		return
 	}
 
+	stats := h.redis.Stats()
+	stats.ConnectionsReceived.Add(1)
+	setKeepAlive(conn)
 	c := connection.MakeConnection(conn)
 
-	h.conn_lock.Lock()
-	h.connections[c] = struct{}{}
-	h.conn_lock.Unlock()
+	clients := h.redis.Clients()
+	if !clients.Add(c, config.Properties().MaxClients) {
+		stats.RejectedConnections.Add(1)
+		// don't let a client which never reads hold the goroutine
+		conn.SetWriteDeadline(time.Now().Add(reject_write_timeout))
+		c.WriteMessage(protocol.MaxClientsReachedError)
+		c.Flush()
+		c.Close()
+		return
+	}
+	stats.ConnectedClients.Add(1)
+	defer func() {
+		clients.Remove(c)
+		stats.ConnectedClients.Add(-1)
+		c.Close()
+		if c.OutputBufferLimitReached() {
+			stats.OutputBufferLimitDisconnections.Add(1)
+		}
+	}()
+
+	p := parser.MakeParser(c)
+	reply := func(msg protocol.RedisMessage) {
+		if c.ReplyMode() != connection.ReplyOff {
+			c.WriteMessage(msg)
+		}
+	}
+	exec := func(args [][]byte) {
+		skip := c.TakeReplySkip()
+		msg := h.redis.Exec(c, args)
+		if skip {
+			msg = protocol.NoReply
+		}
+		reply(msg)
+	}
 
-	ch := parser.Parse(conn)
-	for payload := range ch {
-		if err := payload.Err(); err != nil {
-			if err == io.EOF { // connection closed
+	for {
+		args, err := p.ReadCommand()
+		c.SetQueryBufferSize(p.Buffered())
+		if err != nil {
+			var protoErr *parser.ProtocolError
+			if !errors.As(err, &protoErr) { // connection closed
 				log.Println("Connecton closed")
 				break
 			}
 
-			// make error response
-			msg := protocol.MakeError(err)
-			c.Write(msg.Bytes())
+			// the stream can't be parsed past a malformed command
+			log.Println("Protocol error from client", c.Addr()+":", err)
+			reply(protocol.MakeGenericError(err.Error()))
+			break
+		}
+		c.UpdateLastInteraction()
+
+		// SHUTDOWN waits for the server to be drained,
+		// so it is neither held nor counted as in-flight
+		if server.IsShutdown(args) {
+			c.Flush()
+			exec(args)
+			continue
+		}
+
+		if !h.begin(c) {
+			break
+		}
+		exec(args)
+		h.end()
+
+		if c.HasFlags(connection.FlagCloseAfterReply) {
+			c.Flush()
+			c.Close()
+		}
+		if c.IsClosed() {
+			break
+		}
+
+		if h.closed.Load() {
+			break
+		}
+	}
+	c.Flush()
+}
+
+// setKeepAlive applies tcp-keepalive to tcp connections, 0 disables keepalive
+func setKeepAlive(conn net.Conn) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	interval := config.Properties().TCPKeepAlive
+	if interval == 0 {
+		tcpConn.SetKeepAlive(false)
+		return
+	}
+	tcpConn.SetKeepAlive(true)
+	tcpConn.SetKeepAlivePeriod(time.Duration(interval) * time.Second)
+}
+
+// clientsCron closes clients exceeding their output buffer limits and
+// clients idle for longer than timeout. Pub/sub and blocked clients are
+// waiting for something else than commands, so they are never considered idle.
+func (h *Handler) clientsCron() {
+	timeout := time.Duration(config.Properties().Timeout) * time.Second
+
+	for _, c := range h.redis.Clients().List() {
+		if c.CheckOutputBufferLimits() {
+			continue
+		}
+		if timeout == 0 || c.IsClosed() || c.HasFlags(connection.FlagPubSub|connection.FlagBlocked) || c.IdleTime() <= timeout {
 			continue
 		}
 
-		result := h.redis.Exec(c, payload.Msg().Args())
-		c.Write(result.Bytes())
+		log.Println("closing idle client")
+		h.redis.Stats().IdleTimeoutDisconnections.Add(1)
+		c.Close()
 	}
+}
+
+// begin marks a command as in-flight. While the server is draining, the
+// command is held until draining ends. It returns false if the server closed.
+func (h *Handler) begin(c *connection.Connection) bool {
+	for {
+		h.inflight.Add(1)
+		h.drainLock.Lock()
+		drain := h.drain
+		h.drainLock.Unlock()
+		if drain == nil {
+			if h.closed.Load() {
+				h.inflight.Add(-1)
+				return false
+			}
+			return true
+		}
+
+		h.inflight.Add(-1)
+		c.Flush()
+		<-drain
+		if h.closed.Load() {
+			return false
+		}
+	}
+}
+
+func (h *Handler) end() {
+	h.inflight.Add(-1)
+}
+
+// StartDraining stops accepting connections and holds new commands,
+// so that the in-flight ones can finish
+func (h *Handler) StartDraining() {
+	h.closing.Store(true)
+	h.drainLock.Lock()
+	if h.drain == nil {
+		h.drain = make(chan struct{})
+	}
+	h.drainLock.Unlock()
+}
+
+// StopDraining resumes normal operation after an aborted shutdown
+func (h *Handler) StopDraining() {
+	h.drainLock.Lock()
+	if h.drain != nil {
+		close(h.drain)
+		h.drain = nil
+	}
+	h.drainLock.Unlock()
+	h.closing.Store(h.closed.Load())
+}
 
+// Drained reports whether no command is in-flight, but those held by CLIENT
+// PAUSE. The in-flight ones are counted first: once draining, a command
+// which wasn't counted yet is held before it can be paused.
+func (h *Handler) Drained() bool {
+	return h.inflight.Load() == h.redis.Held()
 }
 
 func (h *Handler) Close() {
 	h.closing.Store(true)
-	h.conn_lock.Lock()
-	for c := range h.connections {
+	h.closed.Store(true)
+	h.StopDraining()
+	for _, c := range h.redis.Clients().List() {
 		c.Close()
 	}
-	h.conn_lock.Unlock()
 
 	h.redis.Close()
 }
@@ -0,0 +1,125 @@
+package tcpserver
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/HwHgoo/Gredis/connection/connectiontest"
+	"github.com/HwHgoo/Gredis/core/server"
+)
+
+// handle serves one end of a tcp connection with a fresh handler and
+// returns the other end
+func handle(tb testing.TB) (*connectiontest.CountingConn, net.Conn) {
+	conn, client := connectiontest.Loopback(tb)
+	redis, err := server.MakeServer()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	go MakeHandler(redis).Handle(context.Background(), conn)
+	return conn, client
+}
+
+func pipeline(n int) ([]byte, []byte) {
+	cmd := []byte("*3\r\n$3\r\nset\r\n$3\r\nkey\r\n$5\r\nvalue\r\n")
+	return bytes.Repeat(cmd, n), bytes.Repeat([]byte("+OK\r\n"), n)
+}
+
+func TestPipelinedReplies(t *testing.T) {
+	conn, client := handle(t)
+	defer client.Close()
+
+	req, expected := pipeline(100)
+	if _, err := client.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	replies := make([]byte, len(expected))
+	if _, err := io.ReadFull(client, replies); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(replies, expected) {
+		t.Fatalf("unexpected replies %q", replies)
+	}
+	if writes := conn.Writes.Load(); writes >= 100 {
+		t.Fatalf("100 pipelined replies took %d writes", writes)
+	}
+}
+
+func TestInlineCommands(t *testing.T) {
+	_, client := handle(t)
+	defer client.Close()
+
+	if _, err := client.Write([]byte("PING\r\nset k \"a\\x20b\"\nget k\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	expected := "+PONG\r\n+OK\r\n$3\r\na b\r\n"
+	replies := make([]byte, len(expected))
+	if _, err := io.ReadFull(client, replies); err != nil {
+		t.Fatal(err)
+	}
+	if string(replies) != expected {
+		t.Fatalf("unexpected replies %q", replies)
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	for _, req := range []string{"get \"k\r\n", "*1\r\n$-1\r\n", "*3\r\n$3\r\nset\r\n:1\r\n"} {
+		_, client := handle(t)
+		defer client.Close()
+
+		// the error is sent and the connection closed, commands after it are ignored
+		if _, err := client.Write([]byte(req + "PING\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		replies, err := io.ReadAll(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(replies, []byte("-ERR Protocol error: ")) || bytes.Count(replies, []byte("\r\n")) != 1 {
+			t.Fatalf("unexpected replies %q to %q", replies, req)
+		}
+	}
+}
+
+func TestPipelinedWrites(t *testing.T) {
+	_, client := handle(t)
+	defer client.Close()
+
+	// the values must outlive the parser buffers they were read into
+	if _, err := client.Write([]byte(command("set", "a", "1111") + command("set", "b", "2222") + command("mget", "a", "b"))); err != nil {
+		t.Fatal(err)
+	}
+	expected := "+OK\r\n+OK\r\n*2\r\n$4\r\n1111\r\n$4\r\n2222\r\n"
+	replies := make([]byte, len(expected))
+	if _, err := io.ReadFull(client, replies); err != nil {
+		t.Fatal(err)
+	}
+	if string(replies) != expected {
+		t.Fatalf("unexpected replies %q", replies)
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	for _, n := range []int{1, 100, 1000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			conn, client := handle(b)
+			defer client.Close()
+			req, expected := pipeline(n)
+			replies := make([]byte, len(expected))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				client.Write(req)
+				if _, err := io.ReadFull(client, replies); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(conn.Writes.Load())/float64(b.N), "writes/op")
+		})
+	}
+}
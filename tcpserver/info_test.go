@@ -0,0 +1,58 @@
+package tcpserver
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/HwHgoo/Gredis/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInfoStats(t *testing.T) {
+	Convey("TestInfoStats", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+		_, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+		So(request(conn, command("config", "resetstat")), ShouldEqual, "+OK\r\n")
+
+		So(request(conn, command("set", "k", "v")), ShouldEqual, "+OK\r\n")
+		So(request(conn, command("get", "k")), ShouldEqual, "$1\r\nv\r\n")
+		So(request(conn, command("get")), ShouldStartWith, "-ERR wrong number of arguments")
+		So(request(conn, command("zadd", "k", "1", "m")), ShouldStartWith, "-WRONGTYPE")
+		So(request(conn, command("no-such-command")), ShouldStartWith, "-ERR unknown command")
+
+		Convey("commandstats", func() {
+			info := request(conn, command("info", "commandstats"))
+			So(info, ShouldContainSubstring, "# Commandstats\r\n")
+			So(info, ShouldContainSubstring, "cmdstat_set:calls=1,")
+			So(info, ShouldContainSubstring, "cmdstat_get:calls=1,")
+			So(info, ShouldContainSubstring, ",rejected_calls=1,failed_calls=0\r\n")
+			So(info, ShouldContainSubstring, "cmdstat_zadd:calls=1,")
+			So(info, ShouldContainSubstring, ",rejected_calls=0,failed_calls=1\r\n")
+			So(info, ShouldContainSubstring, "cmdstat_config|resetstat:calls=1,")
+			So(info, ShouldNotContainSubstring, "cmdstat_ping:")
+			So(info, ShouldNotContainSubstring, "# Errorstats")
+		})
+
+		Convey("errorstats", func() {
+			info := request(conn, command("info"))
+			So(info, ShouldContainSubstring, "\r\ntotal_error_replies:3\r\n")
+			So(info, ShouldContainSubstring, "# Errorstats\r\nerrorstat_ERR:count=2\r\nerrorstat_WRONGTYPE:count=1\r\n")
+			So(info, ShouldNotContainSubstring, "# Commandstats")
+		})
+
+		Convey("reset", func() {
+			So(request(conn, command("config", "resetstat")), ShouldEqual, "+OK\r\n")
+			info := request(conn, command("info", "commandstats", "errorstats"))
+			So(info, ShouldNotContainSubstring, "cmdstat_get:")
+			So(info, ShouldNotContainSubstring, "errorstat_")
+			So(info, ShouldContainSubstring, "cmdstat_config|resetstat:calls=1,")
+		})
+	})
+}
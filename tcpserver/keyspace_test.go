@@ -0,0 +1,66 @@
+package tcpserver
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCopyAndMove(t *testing.T) {
+	Convey("TestCopyAndMove", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+		_, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+		So(request(conn, command("set", "k", "v", "ex", "100")), ShouldEqual, "+OK\r\n")
+
+		Convey("copy", func() {
+			So(request(conn, command("copy", "k", "k")), ShouldEqual, "-ERR source and destination objects are the same\r\n")
+			So(request(conn, command("copy", "k", "k", "db", "16")), ShouldEqual, "-ERR DB index is out of range\r\n")
+			So(request(conn, command("copy", "k", "k", "db")), ShouldEqual, "-ERR syntax error\r\n")
+			So(request(conn, command("copy", "k", "k", "nx")), ShouldEqual, "-ERR syntax error\r\n")
+			So(request(conn, command("copy", "missing", "c")), ShouldEqual, ":0\r\n")
+
+			So(request(conn, command("copy", "k", "c")), ShouldEqual, ":1\r\n")
+			So(request(conn, command("append", "c", "2")), ShouldEqual, ":2\r\n")
+			So(request(conn, command("get", "k")), ShouldEqual, "$1\r\nv\r\n")
+			So(request(conn, command("copy", "k", "c")), ShouldEqual, ":0\r\n")
+			So(request(conn, command("copy", "k", "c", "replace")), ShouldEqual, ":1\r\n")
+			So(request(conn, command("get", "c")), ShouldEqual, "$1\r\nv\r\n")
+
+			So(request(conn, command("copy", "k", "k", "DB", "1")), ShouldEqual, ":1\r\n")
+			So(request(conn, command("select", "1")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("get", "k")), ShouldEqual, "$1\r\nv\r\n")
+		})
+
+		Convey("copy in no-touch mode", func() {
+			time.Sleep(1100 * time.Millisecond)
+			So(request(conn, command("client", "no-touch", "on")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("copy", "k", "c")), ShouldEqual, ":1\r\n")
+			So(request(conn, command("object", "idletime", "k")), ShouldEqual, ":1\r\n")
+		})
+
+		Convey("move", func() {
+			So(request(conn, command("move", "k", "0")), ShouldEqual, "-ERR source and destination objects are the same\r\n")
+			So(request(conn, command("move", "k", "x")), ShouldEqual, "-ERR value is not an integer or out of range\r\n")
+			So(request(conn, command("move", "missing", "1")), ShouldEqual, ":0\r\n")
+
+			So(request(conn, command("move", "k", "1")), ShouldEqual, ":1\r\n")
+			So(request(conn, command("exists", "k")), ShouldEqual, ":0\r\n")
+			So(request(conn, command("set", "k", "other")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("move", "k", "1")), ShouldEqual, ":0\r\n")
+
+			So(request(conn, command("select", "1")), ShouldEqual, "+OK\r\n")
+			So(request(conn, command("get", "k")), ShouldEqual, "$1\r\nv\r\n")
+			So(request(conn, command("type", "k")), ShouldEqual, "+string\r\n")
+		})
+	})
+}
@@ -0,0 +1,93 @@
+package tcpserver
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/HwHgoo/Gredis/config"
+)
+
+// listen opens a tcp listener for every bind address, and if configured,
+// a tls listener for every bind address and a unix domain socket.
+// Either all of them are opened or none.
+func listen() ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0)
+	closeAll := func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}
+
+	props := config.Properties()
+	binds := props.Bind
+	if len(binds) == 0 {
+		binds = []string{""}
+	}
+
+	// port 0 disables plain tcp, e.g. to accept tls connections only
+	if props.Port != 0 {
+		port := strconv.Itoa(props.Port)
+		for _, addr := range binds {
+			lsn, err := net.Listen("tcp", net.JoinHostPort(addr, port))
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			log.Println("listening on", lsn.Addr())
+			listeners = append(listeners, lsn)
+		}
+	}
+
+	if props.TLSPort != 0 {
+		port := strconv.Itoa(props.TLSPort)
+		for _, addr := range binds {
+			lsn, err := listenTLS(net.JoinHostPort(addr, port))
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			log.Println("listening for tls connections on", lsn.Addr())
+			listeners = append(listeners, lsn)
+		}
+	}
+
+	if path := props.UnixSocket; path != "" {
+		lsn, err := listenUnix(path, os.FileMode(props.UnixSocketPerm))
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		log.Println("listening on unix socket", path)
+		listeners = append(listeners, lsn)
+	}
+
+	if len(listeners) == 0 {
+		return nil, errors.New("none of port, tls-port or unixsocket is configured")
+	}
+	return listeners, nil
+}
+
+func listenUnix(path string, perm os.FileMode) (*net.UnixListener, error) {
+	// remove the socket left behind by a previous run
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	lsn, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// the socket file is removed when the listener is closed
+	lsn.SetUnlinkOnClose(true)
+
+	if perm != 0 {
+		if err := os.Chmod(path, perm); err != nil {
+			lsn.Close()
+			return nil, err
+		}
+	}
+	return lsn, nil
+}
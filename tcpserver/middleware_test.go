@@ -0,0 +1,83 @@
+package tcpserver
+
+import (
+	"bufio"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/connection"
+	registry "github.com/HwHgoo/Gredis/core/command"
+	"github.com/HwHgoo/Gredis/core/protocol"
+	"github.com/HwHgoo/Gredis/core/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMiddleware(t *testing.T) {
+	Convey("TestMiddleware", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+
+		audit := make([]string, 0)
+		auditLog := func(next server.Handler) server.Handler {
+			return func(c *connection.Connection, spec *registry.Spec, args [][]byte) protocol.RedisMessage {
+				reply := next(c, spec, args)
+				audit = append(audit, spec.FullName()+" "+strings.TrimSpace(string(reply.Bytes())))
+				return reply
+			}
+		}
+		denyKey := func(next server.Handler) server.Handler {
+			return func(c *connection.Connection, spec *registry.Spec, args [][]byte) protocol.RedisMessage {
+				if spec.Flags&registry.FlagWrite != 0 && string(args[1]) == "denied" {
+					return server.Reject(spec, protocol.MakeGenericError("denied by policy"))
+				}
+				return next(c, spec, args)
+			}
+		}
+		// keeps the arguments, as an asynchronous audit log would
+		kept := make([][]byte, 0)
+		keepKeys := func(next server.Handler) server.Handler {
+			return func(c *connection.Connection, spec *registry.Spec, args [][]byte) protocol.RedisMessage {
+				if spec.Name == "get" {
+					kept = append(kept, args[1])
+				}
+				return next(c, spec, args)
+			}
+		}
+		_, addr, signals, done := serve(auditLog, denyKey, keepKeys)
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+		So(request(conn, command("config", "resetstat")), ShouldEqual, "+OK\r\n")
+		audit = audit[:0]
+
+		So(request(conn, command("set", "allowed", "v")), ShouldEqual, "+OK\r\n")
+		So(request(conn, command("set", "denied", "v")), ShouldEqual, "-ERR denied by policy\r\n")
+		So(request(conn, command("get", "denied")), ShouldEqual, "$-1\r\n")
+		So(audit, ShouldResemble, []string{"set +OK", "set -ERR denied by policy", "get $-1"})
+
+		Convey("kept arguments survive the next commands", func() {
+			kept = kept[:0]
+			_, err := conn.Write([]byte(command("get", "first") + command("get", "other")))
+			So(err, ShouldBeNil)
+			r := bufio.NewReader(conn)
+			for i := 0; i < 2; i++ {
+				line, err := r.ReadString('\n')
+				So(err, ShouldBeNil)
+				So(line, ShouldEqual, "$-1\r\n")
+			}
+			So(len(kept), ShouldEqual, 2)
+			So(string(kept[0]), ShouldEqual, "first")
+			So(string(kept[1]), ShouldEqual, "other")
+		})
+
+		info := request(conn, command("info", "commandstats", "errorstats"))
+		So(info, ShouldContainSubstring, "cmdstat_set:calls=1,")
+		So(info, ShouldContainSubstring, ",rejected_calls=1,failed_calls=0\r\n")
+		So(info, ShouldContainSubstring, "errorstat_ERR:count=1\r\n")
+	})
+}
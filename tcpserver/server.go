@@ -2,50 +2,311 @@ package tcpserver
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
 	"os"
 	"sync"
+	"time"
 
+	"github.com/HwHgoo/Gredis/config"
 	"github.com/HwHgoo/Gredis/core/server"
+	"github.com/HwHgoo/Gredis/module"
 )
 
+var (
+	errShutdownFailed     = errors.New("Errors trying to SHUTDOWN. Check logs.")
+	errNoShutdownProgress = errors.New("No shutdown in progress.")
+	errNotServing         = errors.New("The server is not serving, it can't be shut down.")
+	errNotIdle            = errors.New("the server has been served or closed already")
+)
+
+const (
+	state_idle = iota
+	state_serving
+	state_closed
+)
+
+const (
+	min_accept_delay = 5 * time.Millisecond
+	max_accept_delay = time.Second
+
+	clients_cron_interval = 100 * time.Millisecond
+)
+
+type shutdownRequest struct {
+	flags  int
+	result chan error
+}
+
 type Server struct {
 	handler *Handler
+
+	shutdowns chan shutdownRequest
+	// closed once the server stops serving
+	done chan struct{}
+	// closed by SHUTDOWN ABORT, nil while no shutdown is in progress
+	abort     chan struct{}
+	abortLock sync.Mutex
+
+	state     int
+	stateLock sync.Mutex
+	// closed once Serve has returned
+	stopped chan struct{}
 }
 
-func MakeTcpServer() *Server {
-	redis := server.MakeServer()
-	return &Server{
-		handler: MakeHandler(redis),
+// MakeTcpServer makes a server loading the registered modules along the given ones
+func MakeTcpServer(modules ...*module.Module) (*Server, error) {
+	redis, err := server.MakeServer(modules...)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		handler:   MakeHandler(redis),
+		shutdowns: make(chan shutdownRequest),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
 	}
+	redis.SetShutdownHandler(s.Shutdown)
+	return s, nil
+}
+
+// Redis returns the server executing the commands, e.g. to add middlewares
+func (s *Server) Redis() *server.Server {
+	return s.handler.redis
 }
 
-func (s *Server) ListenAndServe(signals <-chan os.Signal) {
-	lsn, err := net.Listen("tcp", ":3301")
+// ListenAndServe serves on the configured addresses until a signal or a
+// SHUTDOWN command stops the server. It returns an error if the listeners
+// can't be opened.
+func (s *Server) ListenAndServe(signals <-chan os.Signal) error {
+	listeners, err := listen()
 	if err != nil {
-		return
+		return err
+	}
+	return s.Serve(listeners, signals)
+}
+
+// Serve serves on the given listeners until a signal, a SHUTDOWN command or
+// Shutdown stops the server, the listeners are closed then. signals may be
+// nil. A server is only served once.
+func (s *Server) Serve(listeners []net.Listener, signals <-chan os.Signal) error {
+	s.stateLock.Lock()
+	state := s.state
+	if state == state_idle {
+		s.state = state_serving
+	}
+	s.stateLock.Unlock()
+	if state != state_idle {
+		return errNotIdle
+	}
+	defer close(s.stopped)
+
+	// closing a unix listener also removes its socket file
+	closeListeners := func() {
+		for _, lsn := range listeners {
+			_ = lsn.Close()
+		}
+	}
+	defer closeListeners()
+
+	waitHandler := &sync.WaitGroup{}
+	waitAccept := &sync.WaitGroup{}
+	for _, lsn := range listeners {
+		waitAccept.Add(1)
+		go func() {
+			defer waitAccept.Done()
+			s.acceptLoop(lsn, waitHandler)
+		}()
 	}
 
 	go func() {
-		sig := <-signals
-		log.Println("received signal:", sig)
-		_ = lsn.Close()
-		log.Println("closing handler")
-		s.handler.Close()
+		ticker := time.NewTicker(clients_cron_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.handler.clientsCron()
+			case <-s.done:
+				return
+			}
+		}
 	}()
 
-	waitHandler := &sync.WaitGroup{}
+	for {
+		var reqs []shutdownRequest
+		flags := 0
+		select {
+		case sig := <-signals:
+			log.Println("received signal:", sig)
+		case req := <-s.shutdowns:
+			reqs = append(reqs, req)
+			flags = req.flags
+		}
+
+		if s.shutdown(signals, reqs, flags) {
+			break
+		}
+	}
+
+	close(s.done)
+	closeListeners()
+	log.Println("closing handler")
+	s.handler.Close()
+	waitAccept.Wait()
+	waitHandler.Wait()
+	return nil
+}
+
+// shutdown drains in-flight commands for at most shutdown-timeout and
+// persists the dataset. It returns false if the shutdown was aborted or
+// failed, in which case the server keeps serving.
+func (s *Server) shutdown(signals <-chan os.Signal, reqs []shutdownRequest, flags int) bool {
+	reply := func(err error) {
+		for _, req := range reqs {
+			req.result <- err
+		}
+	}
+
+	abort := make(chan struct{})
+	s.abortLock.Lock()
+	s.abort = abort
+	s.abortLock.Unlock()
+	defer func() {
+		s.abortLock.Lock()
+		s.abort = nil
+		s.abortLock.Unlock()
+	}()
+
+	timeout := time.Duration(config.Properties().ShutdownTimeout) * time.Second
+	if flags&server.ShutdownNow != 0 {
+		timeout = 0
+	}
+	log.Println("draining in-flight commands, timeout", timeout)
+	s.handler.StartDraining()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+drain:
+	for !s.handler.Drained() {
+		select {
+		case <-abort:
+			log.Println("shutdown manually aborted")
+			s.handler.StopDraining()
+			reply(errShutdownFailed)
+			return false
+		case req := <-s.shutdowns:
+			reqs = append(reqs, req)
+			flags |= req.flags
+			if flags&server.ShutdownNow != 0 {
+				break drain
+			}
+		case sig := <-signals:
+			log.Println("received signal", sig, "again, exiting now")
+			flags |= server.ShutdownNow | server.ShutdownForce
+			break drain
+		case <-deadline.C:
+			log.Println("shutdown timeout reached, closing connections with commands in-flight")
+			break drain
+		case <-ticker.C:
+		}
+	}
+
+	if err := s.handler.redis.Persist(flags); err != nil {
+		if flags&server.ShutdownForce == 0 {
+			log.Println("error trying to shutdown:", err)
+			s.handler.StopDraining()
+			reply(errShutdownFailed)
+			return false
+		}
+		log.Println("error trying to shutdown:", err, ", exiting anyway")
+	}
+
+	reply(nil)
+	return true
+}
+
+// Shutdown stops Serve like the SHUTDOWN command with the given
+// server.Shutdown* flags, it returns once the server is drained
+func (s *Server) Shutdown(flags int) error {
+	s.stateLock.Lock()
+	state := s.state
+	s.stateLock.Unlock()
+	if state != state_serving {
+		return errNotServing
+	}
+	if flags&server.ShutdownAbort != 0 {
+		s.abortLock.Lock()
+		defer s.abortLock.Unlock()
+		if s.abort == nil {
+			return errNoShutdownProgress
+		}
+		close(s.abort)
+		s.abort = nil
+		return nil
+	}
+
+	req := shutdownRequest{flags: flags, result: make(chan error, 1)}
+	select {
+	case s.shutdowns <- req:
+		return <-req.result
+	case <-s.done:
+		// already exiting
+		return nil
+	}
+}
+
+// ServeConn serves a single connection, e.g. an end of net.Pipe,
+// until it is closed
+func (s *Server) ServeConn(conn net.Conn) {
+	s.handler.Handle(context.Background(), conn)
+}
+
+// Close shuts Serve down without saving and waits for it to return. If the
+// server isn't served, the connections served by ServeConn are closed and
+// Serve won't start anymore.
+func (s *Server) Close() error {
+	s.stateLock.Lock()
+	state := s.state
+	if state == state_idle {
+		s.state = state_closed
+	}
+	s.stateLock.Unlock()
+
+	switch state {
+	case state_serving:
+		err := s.Shutdown(server.ShutdownNoSave)
+		<-s.stopped
+		return err
+	case state_idle:
+		s.handler.Close()
+	}
+	return nil
+}
+
+// acceptLoop accepts connections until the listener is closed. Errors like
+// running out of file descriptors are retried with an increasing delay.
+func (s *Server) acceptLoop(lsn net.Listener, waitHandler *sync.WaitGroup) {
+	var delay time.Duration
 	for {
 		conn, err := lsn.Accept()
 		if err != nil {
-			if ne, ok := err.(net.Error); ok && ne.Timeout() {
-				continue
+			if errors.Is(err, net.ErrClosed) {
+				break
 			}
 
-			log.Println(err)
-			break
+			if delay == 0 {
+				delay = min_accept_delay
+			} else {
+				delay = min(delay*2, max_accept_delay)
+			}
+			log.Println("accept error:", err, ", retrying in", delay)
+			time.Sleep(delay)
+			continue
 		}
+		delay = 0
 
 		waitHandler.Add(1)
 		go func() {
@@ -53,6 +314,4 @@ func (s *Server) ListenAndServe(signals <-chan os.Signal) {
 			s.handler.Handle(context.Background(), conn)
 		}()
 	}
-
-	waitHandler.Wait()
 }
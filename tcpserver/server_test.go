@@ -0,0 +1,237 @@
+package tcpserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/connection"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// dial retries until the server is ready to accept connections
+func dial(network, addr string) net.Conn {
+	for i := 0; i < 100; i++ {
+		conn, err := net.Dial(network, addr)
+		if err == nil {
+			return conn
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func makeTcpServer() *Server {
+	s, err := MakeTcpServer()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func request(conn net.Conn, req string) string {
+	_, err := conn.Write([]byte(req))
+	So(err, ShouldBeNil)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	So(err, ShouldBeNil)
+	// bulk strings, verbatim strings and bulk errors
+	if line[0] == '$' || line[0] == '=' || line[0] == '!' {
+		n, _ := strconv.Atoi(line[1 : len(line)-2])
+		if n >= 0 {
+			bulk := make([]byte, n+2)
+			_, err = io.ReadFull(r, bulk)
+			So(err, ShouldBeNil)
+			line += string(bulk)
+		}
+	}
+	return line
+}
+
+func TestListenAndServe(t *testing.T) {
+	Convey("TestListenAndServe", t, func() {
+		sock := filepath.Join(t.TempDir(), "gredis.sock")
+		So(config.Load("", "port 0\nbind 127.0.0.1\nunixsocket "+sock+"\nunixsocketperm 700"), ShouldBeNil)
+		defer config.Load("", "port 3301\nbind \"\"\nunixsocket \"\"\nunixsocketperm 0")
+
+		Convey("serve on both tcp and unix socket", func() {
+			// pick a free port for the tcp listener
+			lsn, err := net.Listen("tcp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			port := lsn.Addr().(*net.TCPAddr).Port
+			lsn.Close()
+			So(config.Load("", "port "+strconv.Itoa(port)), ShouldBeNil)
+
+			signals := make(chan os.Signal, 1)
+			done := make(chan struct{})
+			go func() {
+				makeTcpServer().ListenAndServe(signals)
+				close(done)
+			}()
+
+			unixConn := dial("unix", sock)
+			So(unixConn, ShouldNotBeNil)
+			defer unixConn.Close()
+			fi, err := os.Stat(sock)
+			So(err, ShouldBeNil)
+			So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0700))
+			So(request(unixConn, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+
+			tcpConn := dial("tcp", lsn.Addr().String())
+			So(tcpConn, ShouldNotBeNil)
+			defer tcpConn.Close()
+			So(request(tcpConn, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+
+			signals <- syscall.SIGTERM
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("server didn't stop")
+			}
+
+			_, err = os.Stat(sock)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("stale socket file is replaced", func() {
+			So(os.WriteFile(sock, nil, 0600), ShouldBeNil)
+			lsn, err := listenUnix(sock, 0)
+			So(err, ShouldBeNil)
+			lsn.Close()
+			_, err = os.Stat(sock)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
+
+func TestMaxClients(t *testing.T) {
+	Convey("TestMaxClients", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301\nmaxclients 10000")
+		_, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+		So(config.Set([][2]string{{"maxclients", "1"}}), ShouldBeNil)
+
+		first := dial("tcp", addr)
+		defer first.Close()
+		So(request(first, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+
+		second := dial("tcp", addr)
+		defer second.Close()
+		second.SetReadDeadline(time.Now().Add(time.Second))
+		reader := bufio.NewReader(second)
+		line, err := reader.ReadString('\n')
+		So(err, ShouldBeNil)
+		So(line, ShouldEqual, "-ERR max number of clients reached\r\n")
+		_, err = reader.ReadByte()
+		So(err, ShouldEqual, io.EOF)
+
+		info := request(first, "*2\r\n$4\r\ninfo\r\n$5\r\nstats\r\n")
+		So(info, ShouldContainSubstring, "rejected_connections:1\r\n")
+		So(info, ShouldNotContainSubstring, "# Server")
+	})
+}
+
+// fakeListener fails with the given errors before being closed
+type fakeListener struct {
+	net.Listener
+	errs []error
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	if len(l.errs) == 0 {
+		return nil, net.ErrClosed
+	}
+	err := l.errs[0]
+	l.errs = l.errs[1:]
+	return nil, err
+}
+
+func TestAcceptBackoff(t *testing.T) {
+	Convey("accept errors are retried with backoff", t, func() {
+		lsn := &fakeListener{errs: []error{syscall.EMFILE, syscall.EMFILE, syscall.ENFILE}}
+		start := time.Now()
+		makeTcpServer().acceptLoop(lsn, &sync.WaitGroup{})
+		So(lsn.errs, ShouldBeEmpty)
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, min_accept_delay*7)
+	})
+}
+
+func TestIdleTimeout(t *testing.T) {
+	Convey("TestIdleTimeout", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301\ntimeout 0")
+		s, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+		So(config.Set([][2]string{{"timeout", "1"}}), ShouldBeNil)
+
+		subscriber := dial("tcp", addr)
+		defer subscriber.Close()
+		So(request(subscriber, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+		for _, c := range s.handler.redis.Clients().List() {
+			c.SetFlags(connection.FlagPubSub)
+		}
+
+		idle := dial("tcp", addr)
+		defer idle.Close()
+		So(request(idle, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+
+		// an active client is never closed
+		active := dial("tcp", addr)
+		defer active.Close()
+		for i := 0; i < 6; i++ {
+			time.Sleep(300 * time.Millisecond)
+			So(request(active, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+		}
+
+		idle.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := idle.Read(make([]byte, 16))
+		So(err, ShouldEqual, io.EOF)
+		So(request(subscriber, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+		So(request(active, "*2\r\n$4\r\ninfo\r\n$5\r\nstats\r\n"), ShouldContainSubstring, "client_idle_timeout_disconnections:1\r\n")
+	})
+}
+
+func TestOutputBufferLimit(t *testing.T) {
+	Convey("a client which doesn't read its replies is closed", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301")
+		s, addr, signals, done := serve()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+		So(config.Set([][2]string{{"client-output-buffer-limit", "normal 1mb 0 0"}}), ShouldBeNil)
+
+		conn := dial("tcp", addr)
+		defer conn.Close()
+		So(request(conn, "*3\r\n$3\r\nset\r\n$3\r\nbig\r\n$1048576\r\n"+strings.Repeat("x", 1<<20)+"\r\n"), ShouldEqual, "+OK\r\n")
+
+		// the replies to the pipeline build up past the limit
+		slow := dial("tcp", addr)
+		defer slow.Close()
+		_, err := slow.Write([]byte(strings.Repeat("*2\r\n$3\r\nget\r\n$3\r\nbig\r\n", 64)))
+		So(err, ShouldBeNil)
+
+		stats := s.handler.redis.Stats()
+		deadline := time.Now().Add(2 * time.Second)
+		for stats.OutputBufferLimitDisconnections.Load() == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(stats.OutputBufferLimitDisconnections.Load(), ShouldEqual, 1)
+		So(request(conn, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+	})
+}
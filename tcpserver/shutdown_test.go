@@ -0,0 +1,173 @@
+package tcpserver
+
+import (
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	"github.com/HwHgoo/Gredis/core/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// serve starts a server on a free local port, with the given middlewares
+func serve(middlewares ...server.Middleware) (s *Server, addr string, signals chan os.Signal, done chan error) {
+	lsn, err := net.Listen("tcp", "127.0.0.1:0")
+	So(err, ShouldBeNil)
+	addr = lsn.Addr().String()
+	lsn.Close()
+	So(config.Load("", "bind 127.0.0.1\nport "+strconv.Itoa(lsn.Addr().(*net.TCPAddr).Port)), ShouldBeNil)
+
+	s = makeTcpServer()
+	s.Redis().Use(middlewares...)
+	signals = make(chan os.Signal, 1)
+	done = make(chan error, 1)
+	go func() {
+		done <- s.ListenAndServe(signals)
+	}()
+	conn := dial("tcp", addr)
+	So(conn, ShouldNotBeNil)
+	So(request(conn, "*2\r\n$6\r\nselect\r\n$1\r\n0\r\n"), ShouldEqual, "+OK\r\n")
+	conn.Close()
+	// wait for the server to notice the probe went away
+	for s.handler.redis.Stats().ConnectedClients.Load() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return
+}
+
+func stopped(done chan error) bool {
+	select {
+	case err := <-done:
+		So(err, ShouldBeNil)
+		return true
+	case <-time.After(2 * time.Second):
+		return false
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	Convey("TestShutdown", t, func() {
+		defer config.Load("", "bind \"\"\nport 3301\nshutdown-timeout 10")
+		s, addr, signals, done := serve()
+
+		Convey("SHUTDOWN stops the server and closes the connection", func() {
+			conn := dial("tcp", addr)
+			defer conn.Close()
+			_, err := conn.Write([]byte("*1\r\n$8\r\nshutdown\r\n"))
+			So(err, ShouldBeNil)
+			So(stopped(done), ShouldBeTrue)
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, err = conn.Read(make([]byte, 16))
+			So(err, ShouldEqual, io.EOF)
+		})
+
+		Convey("SHUTDOWN fails without persistence unless forced", func() {
+			conn := dial("tcp", addr)
+			defer conn.Close()
+			So(request(conn, "*2\r\n$8\r\nshutdown\r\n$4\r\nsave\r\n"), ShouldEqual, "-ERR Errors trying to SHUTDOWN. Check logs.\r\n")
+			So(request(conn, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+
+			_, err := conn.Write([]byte("*3\r\n$8\r\nshutdown\r\n$4\r\nsave\r\n$5\r\nforce\r\n"))
+			So(err, ShouldBeNil)
+			So(stopped(done), ShouldBeTrue)
+		})
+
+		Convey("SHUTDOWN with bad arguments", func() {
+			conn := dial("tcp", addr)
+			defer conn.Close()
+			So(request(conn, "*2\r\n$8\r\nshutdown\r\n$3\r\nfoo\r\n"), ShouldEqual, "-ERR syntax error\r\n")
+			So(request(conn, "*3\r\n$8\r\nshutdown\r\n$4\r\nsave\r\n$6\r\nnosave\r\n"), ShouldEqual, "-ERR syntax error\r\n")
+			So(request(conn, "*2\r\n$8\r\nshutdown\r\n$5\r\nabort\r\n"), ShouldEqual, "-ERR No shutdown in progress.\r\n")
+
+			signals <- os.Interrupt
+			So(stopped(done), ShouldBeTrue)
+		})
+
+		Convey("in-flight commands are waited for", func() {
+			So(s.handler.begin(nil), ShouldBeTrue)
+			signals <- os.Interrupt
+			So(stopped(done), ShouldBeFalse)
+
+			// new connections are refused while draining
+			conn := dial("tcp", addr)
+			So(conn, ShouldNotBeNil)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, err := conn.Read(make([]byte, 16))
+			So(err, ShouldEqual, io.EOF)
+			conn.Close()
+
+			s.handler.end()
+			So(stopped(done), ShouldBeTrue)
+		})
+
+		Convey("commands held by a pause are not waited for", func() {
+			admin := dial("tcp", addr)
+			defer admin.Close()
+			So(request(admin, command("client", "pause", "10000", "all")), ShouldEqual, "+OK\r\n")
+
+			conn := dial("tcp", addr)
+			defer conn.Close()
+			_, err := conn.Write([]byte(command("get", "k")))
+			So(err, ShouldBeNil)
+			for s.handler.redis.Held() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			_, err = admin.Write([]byte(command("shutdown")))
+			So(err, ShouldBeNil)
+			So(stopped(done), ShouldBeTrue)
+		})
+
+		Convey("in-flight commands are abandoned after the timeout", func() {
+			So(config.Set([][2]string{{"shutdown-timeout", "1"}}), ShouldBeNil)
+			So(s.handler.begin(nil), ShouldBeTrue)
+			defer s.handler.end()
+			signals <- os.Interrupt
+			So(stopped(done), ShouldBeTrue)
+		})
+
+		Convey("SHUTDOWN NOW doesn't wait", func() {
+			So(s.handler.begin(nil), ShouldBeTrue)
+			defer s.handler.end()
+			So(s.Shutdown(server.ShutdownNow), ShouldBeNil)
+			So(stopped(done), ShouldBeTrue)
+		})
+
+		Convey("SHUTDOWN ABORT resumes serving", func() {
+			conn := dial("tcp", addr)
+			defer conn.Close()
+			So(request(conn, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+			So(s.handler.begin(nil), ShouldBeTrue)
+
+			result := make(chan error, 1)
+			go func() { result <- s.Shutdown(0) }()
+			time.Sleep(100 * time.Millisecond)
+
+			So(request(conn, "*2\r\n$8\r\nshutdown\r\n$5\r\nabort\r\n"), ShouldEqual, "+OK\r\n")
+			So(<-result, ShouldEqual, errShutdownFailed)
+			s.handler.end()
+			So(request(conn, "*2\r\n$6\r\nselect\r\n$1\r\n1\r\n"), ShouldEqual, "+OK\r\n")
+
+			signals <- os.Interrupt
+			So(stopped(done), ShouldBeTrue)
+		})
+	})
+}
+
+func TestListenError(t *testing.T) {
+	Convey("ListenAndServe returns listen errors", t, func() {
+		lsn, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer lsn.Close()
+		So(config.Load("", "bind 127.0.0.1\nport "+strconv.Itoa(lsn.Addr().(*net.TCPAddr).Port)), ShouldBeNil)
+		defer config.Load("", "bind \"\"\nport 3301")
+
+		err = makeTcpServer().ListenAndServe(make(chan os.Signal))
+		So(err, ShouldNotBeNil)
+	})
+}
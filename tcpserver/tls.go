@@ -0,0 +1,127 @@
+package tcpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/HwHgoo/Gredis/config"
+)
+
+// the tls configuration new connections are handshaked with,
+// replaced whenever one of the tls-* options is changed
+var tlsConfig atomic.Pointer[tls.Config]
+
+func init() {
+	for _, name := range []string{"tls-cert-file", "tls-key-file", "tls-ca-cert-file", "tls-ca-cert-dir", "tls-auth-clients"} {
+		config.OnApply(name, reloadTLSConfig)
+	}
+}
+
+// reloadTLSConfig loads certificates from the configured files again.
+// Established connections keep the certificates they were handshaked with.
+func reloadTLSConfig() error {
+	if config.Properties().TLSPort == 0 {
+		return nil
+	}
+
+	cfg, err := loadTLSConfig()
+	if err != nil {
+		return err
+	}
+	tlsConfig.Store(cfg)
+	return nil
+}
+
+func loadTLSConfig() (*tls.Config, error) {
+	props := config.Properties()
+	if props.TLSCertFile == "" || props.TLSKeyFile == "" {
+		return nil, errors.New("tls-cert-file and tls-key-file are required to use TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(props.TLSCertFile, props.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch props.TLSAuthClients {
+	case "yes":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		cfg.ClientAuth = tls.NoClientCert
+	}
+
+	if props.TLSCACertFile != "" || props.TLSCACertDir != "" {
+		pool, err := loadCACerts(props.TLSCACertFile, props.TLSCACertDir)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+	} else if cfg.ClientAuth != tls.NoClientCert {
+		return nil, errors.New("either tls-ca-cert-file or tls-ca-cert-dir must be specified when tls-auth-clients is enabled")
+	}
+
+	return cfg, nil
+}
+
+func loadCACerts(file, dir string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	files := make([]string, 0)
+	if file != "" {
+		files = append(files, file)
+	}
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+
+	for _, f := range files {
+		pem, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) && f == file {
+			return nil, errors.New("no certificate found in " + f)
+		}
+	}
+	return pool, nil
+}
+
+// listenTLS wraps a tcp listener so that accepted connections are
+// *tls.Conn, handshaked with the configuration current at accept time
+func listenTLS(addr string) (net.Listener, error) {
+	cfg, err := loadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.Store(cfg)
+
+	lsn, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return tls.NewListener(lsn, &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return tlsConfig.Load(), nil
+		},
+	}), nil
+}
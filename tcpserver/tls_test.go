@@ -0,0 +1,161 @@
+package tcpserver
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/HwHgoo/Gredis/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	tls  tls.Certificate
+}
+
+// makeCert creates a certificate signed by parent, or a self-signed CA if parent is nil
+func makeCert(serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	So(err, ShouldBeNil)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "gredis test " + strconv.FormatInt(serial, 10)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	So(err, ShouldBeNil)
+	cert, err := x509.ParseCertificate(der)
+	So(err, ShouldBeNil)
+	return &testCert{cert: cert, key: key, tls: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}}
+}
+
+// writes the certificate and its key as pem files, returning their paths
+func (c *testCert) write(dir, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	So(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600), ShouldBeNil)
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	So(err, ShouldBeNil)
+	So(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600), ShouldBeNil)
+	return certFile, keyFile
+}
+
+func TestTLS(t *testing.T) {
+	Convey("TestTLS", t, func() {
+		dir := t.TempDir()
+		ca := makeCert(1, nil)
+		caFile, _ := ca.write(dir, "ca")
+		serverCertFile, serverKeyFile := makeCert(2, ca).write(dir, "server")
+		client := makeCert(3, ca)
+
+		lsn, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		addr := lsn.Addr().String()
+		lsn.Close()
+		_, port, _ := net.SplitHostPort(addr)
+
+		So(config.Load("", "port 0\nbind 127.0.0.1\ntls-port "+port+
+			"\ntls-cert-file "+serverCertFile+"\ntls-key-file "+serverKeyFile+
+			"\ntls-ca-cert-file "+caFile+"\ntls-auth-clients yes"), ShouldBeNil)
+		defer config.Load("", "port 3301\nbind \"\"\ntls-port 0\ntls-cert-file \"\"\ntls-key-file \"\"\ntls-ca-cert-file \"\"\ntls-auth-clients yes")
+
+		signals := make(chan os.Signal, 1)
+		done := make(chan struct{})
+		go func() {
+			makeTcpServer().ListenAndServe(signals)
+			close(done)
+		}()
+		defer func() {
+			signals <- syscall.SIGTERM
+			<-done
+		}()
+		ready := dial("tcp", addr)
+		So(ready, ShouldNotBeNil)
+		ready.Close()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		tlsDial := func(certs ...tls.Certificate) (*tls.Conn, error) {
+			return tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, Certificates: certs})
+		}
+		selectDb := func(conn *tls.Conn) (string, error) {
+			if _, err := conn.Write([]byte("*2\r\n$6\r\nselect\r\n$1\r\n1\r\n")); err != nil {
+				return "", err
+			}
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			return bufio.NewReader(conn).ReadString('\n')
+		}
+
+		Convey("client with a certificate signed by the CA", func() {
+			conn, err := tlsDial(client.tls)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			reply, err := selectDb(conn)
+			So(err, ShouldBeNil)
+			So(reply, ShouldEqual, "+OK\r\n")
+			So(conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), ShouldEqual, 2)
+		})
+
+		Convey("client without a certificate is rejected", func() {
+			conn, err := tlsDial()
+			if err == nil {
+				defer conn.Close()
+				_, err = selectDb(conn)
+			}
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("client certificate is optional", func() {
+			So(config.Set([][2]string{{"tls-auth-clients", "optional"}}), ShouldBeNil)
+			conn, err := tlsDial()
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			reply, err := selectDb(conn)
+			So(err, ShouldBeNil)
+			So(reply, ShouldEqual, "+OK\r\n")
+		})
+
+		Convey("certificates are reloaded by CONFIG SET", func() {
+			newCertFile, newKeyFile := makeCert(4, ca).write(dir, "server-new")
+			So(config.Set([][2]string{{"tls-cert-file", newCertFile}, {"tls-key-file", newKeyFile}}), ShouldBeNil)
+
+			conn, err := tlsDial(client.tls)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			So(conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), ShouldEqual, 4)
+
+			// a broken certificate is refused and the old one stays in use
+			So(config.Set([][2]string{{"tls-cert-file", caFile}}), ShouldNotBeNil)
+			So(config.Properties().TLSCertFile, ShouldEqual, newCertFile)
+		})
+	})
+}
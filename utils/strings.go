@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// RadomString returns a random alphanumeric string of the given length
+func RadomString(length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = charset[rand.Intn(len(charset))]
+	}
+	return string(b)
+}
+
+// FloatBytes formats a float the way redis replies scores,
+// using the shortest representation, inf/-inf for infinities and nan.
+func FloatBytes(f float64) []byte {
+	if math.IsNaN(f) {
+		return []byte("nan")
+	} else if math.IsInf(f, 1) {
+		return []byte("inf")
+	} else if math.IsInf(f, -1) {
+		return []byte("-inf")
+	}
+	return strconv.AppendFloat(nil, f, 'g', -1, 64)
+}